@@ -1,13 +1,18 @@
 package bitwarden
 
+import "context"
+
 type AccountService struct {
 	client *Client
 }
 
-func (c *AccountService) GetProfile() (Account, error) {
-	req, err := c.client.newRequest("GET", "accounts/profile", nil)
-
+func (c *AccountService) GetProfile(ctx context.Context) (Account, error) {
 	var account Account
+	req, err := c.client.newRequest(ctx, "GET", "accounts/profile", nil)
+	if err != nil {
+		return account, err
+	}
+
 	_, err = c.client.do(req, &account)
 	if err != nil {
 		return account, err
@@ -15,3 +20,19 @@ func (c *AccountService) GetProfile() (Account, error) {
 
 	return account, err
 }
+
+// Prelogin returns the KDF settings needed to derive the master key of the
+// account with the given email address. It doesn't require authentication.
+func (c *AccountService) Prelogin(ctx context.Context, email string) (KdfConfig, error) {
+	var kdf KdfConfig
+	body := struct {
+		Email string `json:"email"`
+	}{email}
+	req, err := c.client.newBaseRequest(ctx, c.client.IdentityBaseURL, "POST", "accounts/prelogin", body)
+	if err != nil {
+		return kdf, err
+	}
+
+	_, err = c.client.do(req, &kdf)
+	return kdf, err
+}
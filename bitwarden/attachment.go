@@ -0,0 +1,439 @@
+package bitwarden
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/andreburgaud/crypt2go/padding"
+)
+
+// ErrAttachmentMAC is returned at the end of a download if the attachment
+// failed authentication. Everything read from it must be discarded.
+var ErrAttachmentMAC = errors.New("bitwarden: attachment MAC doesn't match")
+
+// An encrypted attachment is stored as the encryption type, IV and MAC
+// followed by the cipher text.
+const attachmentHeaderSize = 1 + aes.BlockSize + sha256.Size
+
+const attachmentChunkSize = 64 << 10
+
+type AttachmentService struct {
+	client *Client
+}
+
+// AddAttachment encrypts the content of r with a new attachment key and
+// uploads it to cipher. fileName is given in plain text. The encrypted
+// content is spooled to a temporary file first, as its MAC has to be sent
+// before it. The updated cipher is returned encrypted.
+func (c *AttachmentService) AddAttachment(ctx context.Context, cipher *EncryptedCipher, fileName string, r io.Reader, keys KeyProvider) (*EncryptedCipher, error) {
+	mk, err := cipher.key(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	kb := make([]byte, 64)
+	if _, err := io.ReadFull(rand.Reader, kb); err != nil {
+		return nil, err
+	}
+	defer zero(kb)
+	key, err := NewCryptoKey(kb, AesCbc256_HmacSha256_B64)
+	if err != nil {
+		return nil, err
+	}
+	encKey, err := Encrypt(kb, mk)
+	if err != nil {
+		return nil, err
+	}
+	encName, err := Encrypt([]byte(fileName), mk)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := newEncryptedFile(r, key)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	areq := AttachmentRequest{Key: encKey, FileName: encName, FileSize: f.size}
+	req, err := c.client.newRequest(ctx, "POST", "ciphers/"+cipher.Id+"/attachment/v2", areq)
+	if err != nil {
+		return nil, err
+	}
+	upload := AttachmentUploadDataResponse{}
+	_, err = c.client.do(req, &upload)
+	if err != nil {
+		return nil, err
+	}
+
+	path := "ciphers/" + cipher.Id + "/attachment/" + upload.AttachmentId
+	err = c.client.uploadFile(ctx, upload.FileUploadType, path, upload.Url, encName.String(), f)
+	if err != nil {
+		// Don't leave an attachment without data behind.
+		c.DeleteAttachment(context.WithoutCancel(ctx), cipher.Id, upload.AttachmentId)
+		return nil, err
+	}
+
+	var ci EncryptedCipher
+	switch {
+	case upload.CipherResponse != nil:
+		ci, err = upload.CipherResponse.ToCipher()
+	case upload.CipherMiniResponse != nil:
+		ci, err = upload.CipherMiniResponse.ToCipher()
+	default:
+		return nil, errors.New("bitwarden: missing cipher in upload response")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ci, nil
+}
+
+// encryptedFile is an encrypted attachment or file Send spooled to a
+// temporary file, as the MAC has to be sent before the cipher text.
+type encryptedFile struct {
+	f      *os.File
+	header []byte
+	ct     *io.SectionReader
+	size   int64
+}
+
+func newEncryptedFile(r io.Reader, key CryptoKey) (*encryptedFile, error) {
+	f, err := os.CreateTemp("", "bitwarden-file-")
+	if err != nil {
+		return nil, err
+	}
+	header, size, err := encryptAttachment(f, r, key)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	ct := io.NewSectionReader(f, 0, size-attachmentHeaderSize)
+	return &encryptedFile{f: f, header: header, ct: ct, size: size}, nil
+}
+
+// body returns the encrypted file between head and tail.
+func (f *encryptedFile) body(head, tail []byte) io.ReadCloser {
+	return io.NopCloser(io.MultiReader(bytes.NewReader(head), bytes.NewReader(f.header), io.NewSectionReader(f.ct, 0, f.ct.Size()), bytes.NewReader(tail)))
+}
+
+// setBody makes f, between head and tail, the body of req. The body can be
+// rewound for retries.
+func (f *encryptedFile) setBody(req *http.Request, head, tail []byte) {
+	req.GetBody = func() (io.ReadCloser, error) {
+		return f.body(head, tail), nil
+	}
+	req.Body = f.body(head, tail)
+	req.ContentLength = int64(len(head)) + f.size + int64(len(tail))
+}
+
+func (f *encryptedFile) Close() error {
+	f.f.Close()
+	return os.Remove(f.f.Name())
+}
+
+// uploadFile uploads the data of an attachment or file Send in the way
+// requested by the server. path is the API endpoint for direct uploads, u
+// the URL for uploads to blob storage.
+func (c *Client) uploadFile(ctx context.Context, uploadType int, path, u, encName string, f *encryptedFile) error {
+	switch uploadType {
+	case FileUploadType_Direct:
+		return c.uploadDirect(ctx, path, encName, f)
+	case FileUploadType_Azure:
+		return c.uploadAzure(ctx, u, f)
+	}
+	return fmt.Errorf("bitwarden: unsupported file upload type %d", uploadType)
+}
+
+// uploadDirect sends the file to the API as a multipart form.
+func (c *Client) uploadDirect(ctx context.Context, path, encName string, f *encryptedFile) error {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if _, err := mw.CreateFormFile("data", encName); err != nil {
+		return err
+	}
+	head := append([]byte(nil), buf.Bytes()...)
+	buf.Reset()
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	tail := buf.Bytes()
+
+	req, err := c.newRequest(ctx, "POST", path, nil)
+	if err != nil {
+		return err
+	}
+	f.setBody(req, head, tail)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	_, err = c.do(req, nil)
+	return err
+}
+
+// uploadAzure puts the file to the blob storage URL handed out by the
+// server. The URL is signed, so the access token isn't sent along.
+func (c *Client) uploadAzure(ctx context.Context, u string, f *encryptedFile) error {
+	req, err := http.NewRequestWithContext(ctx, "PUT", u, nil)
+	if err != nil {
+		return err
+	}
+	f.setBody(req, nil, nil)
+	req.Header.Set("User-Agent", c.UserAgent)
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	req.Header.Set("x-ms-version", "2020-04-08")
+
+	resp, err := c.sendWith(c.baseClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return CheckResponse(resp)
+}
+
+// downloadFile downloads an encrypted file from u and decrypts it with key
+// while it is read. The URL carries its own authorization and may point to
+// another host, so the access token isn't sent along.
+func (c *Client) downloadFile(ctx context.Context, u string, key CryptoKey) (io.ReadCloser, error) {
+	ru, err := c.APIBaseURL.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", ru.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.UserAgent)
+
+	resp, err := c.sendWith(c.baseClient, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	r, err := newDecryptReader(resp.Body, key)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetAttachment returns the metadata of an attachment, including a fresh
+// download URL.
+func (c *AttachmentService) GetAttachment(ctx context.Context, cipherId, attachmentId string) (*Attachment, error) {
+	req, err := c.client.newRequest(ctx, "GET", "ciphers/"+cipherId+"/attachment/"+attachmentId, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	a := Attachment{}
+	_, err = c.client.do(req, &a)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Download returns a reader for the decrypted content of an attachment of
+// cipher. The content is decrypted while it is read and authenticated at the
+// end: if the last Read returns an error other than io.EOF, such as
+// ErrAttachmentMAC, all data read before must be discarded.
+func (c *AttachmentService) Download(ctx context.Context, cipher *EncryptedCipher, attachmentId string, keys KeyProvider) (io.ReadCloser, error) {
+	mk, err := cipher.key(keys)
+	if err != nil {
+		return nil, err
+	}
+	a, err := c.GetAttachment(ctx, cipher.Id, attachmentId)
+	if err != nil {
+		return nil, err
+	}
+
+	key := mk
+	if !a.Key.IsZero() {
+		key, err = a.Key.DecryptKey(mk, AesCbc256_HmacSha256_B64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c.client.downloadFile(ctx, a.Url, key)
+}
+
+// DeleteAttachment deletes an attachment and its data.
+func (c *AttachmentService) DeleteAttachment(ctx context.Context, cipherId, attachmentId string) error {
+	req, err := c.client.newRequest(ctx, "DELETE", "ciphers/"+cipherId+"/attachment/"+attachmentId, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.do(req, nil)
+	return err
+}
+
+// encryptAttachment writes the cipher text of src to dst and returns the
+// attachment header and the total size of the encrypted attachment.
+func encryptAttachment(dst io.Writer, src io.Reader, key CryptoKey) ([]byte, int64, error) {
+	if len(key.MacKey) == 0 {
+		return nil, 0, errors.New("bitwarden: attachment key without MAC key")
+	}
+	block, err := aes.NewCipher(key.EncKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, 0, err
+	}
+	mode := cipher.NewCBCEncrypter(block, iv)
+	mac := hmac.New(sha256.New, key.MacKey)
+	mac.Write(iv)
+
+	size := int64(attachmentHeaderSize)
+	buf := make([]byte, attachmentChunkSize+aes.BlockSize)
+	pending := 0
+	for {
+		n, rerr := src.Read(buf[pending:attachmentChunkSize])
+		pending += n
+		if rerr != nil && rerr != io.EOF {
+			return nil, 0, rerr
+		}
+
+		var ct []byte
+		if rerr == io.EOF {
+			ct, _ = padding.NewPkcs7Padding(aes.BlockSize).Pad(buf[:pending])
+		} else {
+			ct = buf[:pending-pending%aes.BlockSize]
+		}
+		mode.CryptBlocks(ct, ct)
+		mac.Write(ct)
+		if _, err := dst.Write(ct); err != nil {
+			return nil, 0, err
+		}
+		size += int64(len(ct))
+		if rerr == io.EOF {
+			break
+		}
+		pending = copy(buf, buf[len(ct):pending])
+	}
+
+	header := make([]byte, 0, attachmentHeaderSize)
+	header = append(header, AesCbc256_HmacSha256_B64)
+	header = append(header, iv...)
+	header = mac.Sum(header)
+	return header, size, nil
+}
+
+type decryptReader struct {
+	r    io.ReadCloser
+	mode cipher.BlockMode
+	mac  hash.Hash
+	sum  []byte
+	buf  []byte
+	ct   []byte // not yet decrypted, the last block holds the padding
+	pt   []byte // decrypted, not yet returned
+	err  error
+}
+
+func newDecryptReader(r io.ReadCloser, key CryptoKey) (*decryptReader, error) {
+	header := make([]byte, attachmentHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("bitwarden: reading attachment header: %w", err)
+	}
+	if header[0] != AesCbc256_HmacSha256_B64 {
+		return nil, errors.New("bitwarden: unsupported attachment encryption type " + strconv.Itoa(int(header[0])))
+	}
+	if len(key.MacKey) == 0 {
+		return nil, errors.New("bitwarden: attachment key without MAC key")
+	}
+	block, err := aes.NewCipher(key.EncKey)
+	if err != nil {
+		return nil, err
+	}
+	iv := header[1 : 1+aes.BlockSize]
+	d := &decryptReader{
+		r:    r,
+		mode: cipher.NewCBCDecrypter(block, iv),
+		mac:  hmac.New(sha256.New, key.MacKey),
+		sum:  header[1+aes.BlockSize:],
+		buf:  make([]byte, attachmentChunkSize),
+	}
+	d.mac.Write(iv)
+	return d, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.pt) == 0 && d.err == nil {
+		d.fill()
+	}
+	if len(d.pt) > 0 {
+		n := copy(p, d.pt)
+		d.pt = d.pt[n:]
+		return n, nil
+	}
+	return 0, d.err
+}
+
+func (d *decryptReader) fill() {
+	n, err := d.r.Read(d.buf)
+	d.mac.Write(d.buf[:n])
+	d.ct = append(d.ct, d.buf[:n]...)
+	if err == io.EOF {
+		d.finish()
+		return
+	}
+	if err != nil {
+		d.err = err
+		return
+	}
+
+	k := 0
+	if len(d.ct) > aes.BlockSize {
+		k = (len(d.ct) - 1) / aes.BlockSize * aes.BlockSize
+	}
+	if k > 0 {
+		d.mode.CryptBlocks(d.ct[:k], d.ct[:k])
+		d.pt = append(d.pt[:0], d.ct[:k]...)
+		d.ct = append(d.ct[:0], d.ct[k:]...)
+	}
+}
+
+func (d *decryptReader) finish() {
+	if !hmac.Equal(d.mac.Sum(nil), d.sum) {
+		d.err = ErrAttachmentMAC
+		return
+	}
+	if len(d.ct) == 0 || len(d.ct)%aes.BlockSize != 0 {
+		d.err = errors.New("bitwarden: invalid attachment length")
+		return
+	}
+	d.mode.CryptBlocks(d.ct, d.ct)
+	pt, err := padding.NewPkcs7Padding(aes.BlockSize).Unpad(d.ct)
+	if err != nil {
+		d.err = err
+		return
+	}
+	d.pt = append(d.pt[:0], pt...)
+	d.ct = nil
+	d.err = io.EOF
+}
+
+func (d *decryptReader) Close() error {
+	return d.r.Close()
+}
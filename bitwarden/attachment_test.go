@@ -0,0 +1,152 @@
+package bitwarden
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"testing/iotest"
+)
+
+func TestAttachmentEncryption(t *testing.T) {
+	_, key := testKeys(t)
+
+	for _, size := range []int{0, 1, 15, 16, 17, attachmentChunkSize - 1, attachmentChunkSize, 3*attachmentChunkSize + 5} {
+		pt := make([]byte, size)
+		for i := range pt {
+			pt[i] = byte(i * 7)
+		}
+
+		var ct bytes.Buffer
+		header, n, err := encryptAttachment(&ct, iotest.HalfReader(bytes.NewReader(pt)), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(len(header)+ct.Len()) || ct.Len() != (size/16+1)*16 {
+			t.Fatalf("size %d: unexpected encrypted size %d", size, n)
+		}
+		enc := append(header, ct.Bytes()...)
+
+		r, err := newDecryptReader(io.NopCloser(iotest.OneByteReader(bytes.NewReader(enc))), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, pt) {
+			t.Errorf("size %d: decrypted content doesn't match", size)
+		}
+
+		enc[len(enc)-1] ^= 1
+		r, err = newDecryptReader(io.NopCloser(bytes.NewReader(enc)), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(r); !errors.Is(err, ErrAttachmentMAC) {
+			t.Errorf("size %d: expected ErrAttachmentMAC got %v", size, err)
+		}
+	}
+}
+
+type authTransport struct{}
+
+func (authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer token")
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestAttachmentService(t *testing.T) {
+	_, userKey := testKeys(t)
+	content := bytes.Repeat([]byte("ssh-ed25519 AAAA\n"), 1000)
+
+	var meta AttachmentRequest
+	var data []byte
+	var ts *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ciphers/c1/attachment/v2", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&meta)
+		w.Write([]byte(`{"attachmentId":"a1","url":"/ciphers/c1/attachment/a1","fileUploadType":0,
+			"cipherResponse":{"id":"c1","type":1,"data":{},"attachments":[{"id":"a1","fileName":"` + meta.FileName.String() + `","key":"` + meta.Key.String() + `","size":"` + strconv.FormatInt(meta.FileSize, 10) + `"}]},"object":"attachment-fileUpload"}`))
+	})
+	mux.HandleFunc("/ciphers/c1/attachment/a1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			t.Errorf("Missing access token for %s %s", r.Method, r.URL)
+		}
+		switch r.Method {
+		case "POST":
+			f, _, err := r.FormFile("data")
+			if err != nil {
+				t.Error(err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data, _ = io.ReadAll(f)
+		case "GET":
+			w.Write([]byte(`{"id":"a1","url":"` + ts.URL + `/files/a1","fileName":"` + meta.FileName.String() + `","key":"` + meta.Key.String() + `","size":"` + strconv.Itoa(len(data)) + `","object":"attachment"}`))
+		case "DELETE":
+			data = nil
+		}
+	})
+	mux.HandleFunc("/files/a1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Error("Access token sent to storage host")
+		}
+		w.Write(data)
+	})
+	ts = httptest.NewServer(mux)
+	defer ts.Close()
+
+	c, err := NewClient(WithAPIURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.httpClient = &http.Client{Transport: authTransport{}}
+	ctx := context.Background()
+
+	cipher := &EncryptedCipher{Id: "c1", Type: CipherType_Login}
+	ci, err := c.Attachment.AddAttachment(ctx, cipher, "id_ed25519.pub", bytes.NewReader(content), userKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(data)) != meta.FileSize {
+		t.Errorf("Expected %d bytes uploaded got %d", meta.FileSize, len(data))
+	}
+	if len(ci.Attachments) != 1 || ci.Attachments[0].Size.String() != strconv.Itoa(len(data)) {
+		t.Fatalf("Unexpected attachments %+v", ci.Attachments)
+	}
+	v, err := ci.Decrypt(userKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Attachments[0].FileName != "id_ed25519.pub" {
+		t.Errorf("Unexpected file name %q", v.Attachments[0].FileName)
+	}
+
+	r, err := c.Attachment.Download(ctx, cipher, "a1", userKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("Downloaded content doesn't match")
+	}
+
+	if err := c.Attachment.DeleteAttachment(ctx, "c1", "a1"); err != nil {
+		t.Fatal(err)
+	}
+	if data != nil {
+		t.Error("Expected attachment to be deleted")
+	}
+}
@@ -2,29 +2,338 @@ package bitwarden
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
 )
 
-func NewUserPasswordAuthClient(username string, password string) (*Client, error) {
-	c := NewClient(nil)
+const (
+	clientID   = "browser"
+	deviceType = 21 // SDK
+	deviceName = "go-bitwarden"
+)
 
-	dk := MakeKey(password, username)
-	password_hash := HashPassword(password, dk)
+type TwoFactorProvider int
+
+const (
+	TwoFactorProvider_Authenticator   TwoFactorProvider = 0
+	TwoFactorProvider_Email           TwoFactorProvider = 1
+	TwoFactorProvider_Duo             TwoFactorProvider = 2
+	TwoFactorProvider_YubiKey         TwoFactorProvider = 3
+	TwoFactorProvider_U2f             TwoFactorProvider = 4
+	TwoFactorProvider_Remember        TwoFactorProvider = 5
+	TwoFactorProvider_OrganizationDuo TwoFactorProvider = 6
+	TwoFactorProvider_WebAuthn        TwoFactorProvider = 7
+)
+
+func (p TwoFactorProvider) String() string {
+	switch p {
+	case TwoFactorProvider_Authenticator:
+		return "authenticator"
+	case TwoFactorProvider_Email:
+		return "email"
+	case TwoFactorProvider_Duo:
+		return "duo"
+	case TwoFactorProvider_YubiKey:
+		return "yubikey"
+	case TwoFactorProvider_U2f:
+		return "u2f"
+	case TwoFactorProvider_Remember:
+		return "remember"
+	case TwoFactorProvider_OrganizationDuo:
+		return "organization-duo"
+	case TwoFactorProvider_WebAuthn:
+		return "webauthn"
+	}
+	return strconv.Itoa(int(p))
+}
+
+// TwoFactorRequiredError is returned by the login functions when the account
+// has two-factor authentication enabled and no valid token was given with
+// WithTwoFactor. errors.Is(err, ErrTwoFactorRequired) reports true for it.
+// Codes for TwoFactorProvider_Email are only sent on request, see
+// SendEmailLoginCode.
+type TwoFactorRequiredError struct {
+	*HttpErrorResponse
+
+	// Providers enabled for the account, in ascending order.
+	Providers []TwoFactorProvider
+	// ProviderData holds provider specific details such as the obfuscated
+	// address for TwoFactorProvider_Email.
+	ProviderData map[TwoFactorProvider]json.RawMessage
+}
+
+func (e *TwoFactorRequiredError) Error() string {
+	names := make([]string, len(e.Providers))
+	for i, p := range e.Providers {
+		names[i] = p.String()
+	}
+	return fmt.Sprintf("%v (available providers: %s)", ErrTwoFactorRequired, strings.Join(names, ", "))
+}
+
+func (e *TwoFactorRequiredError) Unwrap() error {
+	return e.HttpErrorResponse
+}
+
+func newTwoFactorRequiredError(herr *HttpErrorResponse) *TwoFactorRequiredError {
+	e := &TwoFactorRequiredError{HttpErrorResponse: herr, ProviderData: make(map[TwoFactorProvider]json.RawMessage)}
+	for k, v := range herr.TwoFactorProviders2 {
+		p, err := strconv.Atoi(k)
+		if err != nil {
+			continue
+		}
+		e.Providers = append(e.Providers, TwoFactorProvider(p))
+		e.ProviderData[TwoFactorProvider(p)] = v
+	}
+	sort.Slice(e.Providers, func(i, j int) bool { return e.Providers[i] < e.Providers[j] })
+	return e
+}
+
+type twoFactor struct {
+	provider TwoFactorProvider
+	token    string
+	remember bool
+}
+
+// WithTwoFactor supplies the two-factor token for the login, typically in a
+// second attempt after a *TwoFactorRequiredError. If remember is set, the
+// server issues Client.TwoFactorRememberToken, which can be used for later
+// logins from the same device with TwoFactorProvider_Remember.
+func WithTwoFactor(provider TwoFactorProvider, token string, remember bool) Option {
+	return func(c *Client) error {
+		c.twoFactor = &twoFactor{provider: provider, token: token, remember: remember}
+		return nil
+	}
+}
+
+// WithDeviceIdentifier sets the device identifier sent on login. The server
+// binds remembered two-factor tokens to it, so it should be stable across
+// logins. A random identifier is used by default.
+func WithDeviceIdentifier(id string) Option {
+	return func(c *Client) error {
+		c.DeviceIdentifier = id
+		return nil
+	}
+}
+
+// tokenResponse is the response of the identity server's token endpoint.
+type tokenResponse struct {
+	AccessToken    string `json:"access_token"`
+	TokenType      string `json:"token_type"`
+	RefreshToken   string `json:"refresh_token"`
+	ExpiresIn      int    `json:"expires_in"`
+	TwoFactorToken string
+}
+
+func (t *tokenResponse) token() *oauth2.Token {
+	tok := &oauth2.Token{AccessToken: t.AccessToken, TokenType: t.TokenType, RefreshToken: t.RefreshToken}
+	if t.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+	return tok
+}
+
+// deviceParams returns the device information the identity server expects
+// with every token request.
+func (c *Client) deviceParams() url.Values {
+	return url.Values{
+		"deviceType":       {strconv.Itoa(deviceType)},
+		"deviceIdentifier": {c.DeviceIdentifier},
+		"deviceName":       {deviceName},
+	}
+}
+
+// requestToken posts form to the identity server's token endpoint.
+func (c *Client) requestToken(ctx context.Context, form url.Values, header http.Header) (*tokenResponse, error) {
+	for k, v := range c.deviceParams() {
+		form[k] = v
+	}
+	if c.twoFactor != nil {
+		form.Set("twoFactorProvider", strconv.Itoa(int(c.twoFactor.provider)))
+		form.Set("twoFactorToken", c.twoFactor.token)
+		if c.twoFactor.remember {
+			form.Set("twoFactorRemember", "1")
+		} else {
+			form.Set("twoFactorRemember", "0")
+		}
+	}
+
+	u := c.tokenURL()
+	req, err := http.NewRequestWithContext(ctx, "POST", u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.UserAgent)
+
+	var tr tokenResponse
+	_, err = c.do(req, &tr)
+	var herr *HttpErrorResponse
+	if errors.As(err, &herr) && errors.Is(herr, ErrTwoFactorRequired) {
+		return nil, newTwoFactorRequiredError(herr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.TwoFactorRememberToken = tr.TwoFactorToken
+	return &tr, nil
+}
 
-	ctx := context.Background()
+func (c *Client) tokenURL() string {
 	rel := &url.URL{Path: "connect/token"}
-	u := c.IdentityBaseURL.ResolveReference(rel).String()
+	return c.IdentityBaseURL.ResolveReference(rel).String()
+}
+
+// tokenSourceContext returns the context used by token sources to refresh
+// tokens. Values carried by ctx are kept, its cancellation is not.
+func (c *Client) tokenSourceContext(ctx context.Context) context.Context {
+	return context.WithValue(context.WithoutCancel(ctx), oauth2.HTTPClient, c.httpClient)
+}
 
-	config := &oauth2.Config{ClientID: "browser", Endpoint: oauth2.Endpoint{TokenURL: u}}
+// NewUserPasswordAuthClient logs in with the password grant. If the account
+// uses two-factor authentication, a *TwoFactorRequiredError is returned and
+// the login has to be repeated with WithTwoFactor.
+func NewUserPasswordAuthClient(ctx context.Context, username string, password string, opts ...Option) (*Client, error) {
+	c, dk, err := newUserPasswordAuthClient(ctx, username, password, opts...)
+	zero(dk.EncKey)
+	return c, err
+}
+
+// SendEmailLoginCode asks the server to email a two-factor code for logging
+// in with TwoFactorProvider_Email, as listed by a *TwoFactorRequiredError.
+// The code is then passed to NewUserPasswordAuthClient or Login with
+// WithTwoFactor. Use the same options for both calls.
+func SendEmailLoginCode(ctx context.Context, username string, password string, opts ...Option) error {
+	c, err := NewClient(opts...)
+	if err != nil {
+		return err
+	}
 
-	tok, err := config.PasswordCredentialsToken(ctx, username, password_hash)
+	username = strings.ToLower(strings.TrimSpace(username))
+	dk, err := c.makeKey(ctx, username, password)
+	if err != nil {
+		return err
+	}
+	defer zero(dk.EncKey)
+
+	body := TwoFactorEmailRequest{Email: username, MasterPasswordHash: HashPassword(password, dk), DeviceIdentifier: c.DeviceIdentifier}
+	req, err := c.newRequest(ctx, "POST", "two-factor/send-email-login", body)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(req, nil)
+	return err
+}
+
+// newUserPasswordAuthClient is NewUserPasswordAuthClient, also returning the
+// master key so that it doesn't have to be derived again for unlocking.
+func newUserPasswordAuthClient(ctx context.Context, username string, password string, opts ...Option) (*Client, CryptoKey, error) {
+	c, err := NewClient(opts...)
+	if err != nil {
+		return nil, CryptoKey{}, err
+	}
+
+	username = strings.ToLower(strings.TrimSpace(username))
+	dk, err := c.makeKey(ctx, username, password)
+	if err != nil {
+		return nil, CryptoKey{}, err
+	}
+	password_hash := HashPassword(password, dk)
+
+	form := url.Values{
+		"grant_type": {"password"},
+		"username":   {username},
+		"password":   {password_hash},
+		"scope":      {"api offline_access"},
+		"client_id":  {clientID},
+	}
+	header := http.Header{"Auth-Email": {base64.RawURLEncoding.EncodeToString([]byte(username))}}
+	tr, err := c.requestToken(ctx, form, header)
+	if err != nil {
+		zero(dk.EncKey)
+		return nil, CryptoKey{}, err
+	}
+
+	config := &oauth2.Config{ClientID: clientID, Endpoint: oauth2.Endpoint{TokenURL: c.tokenURL(), AuthStyle: oauth2.AuthStyleInParams}}
+	ctx = c.tokenSourceContext(ctx)
+	c.httpClient = oauth2.NewClient(ctx, config.TokenSource(ctx, tr.token()))
+
+	return c, dk, nil
+}
+
+// NewAPIKeyAuthClient logs in with a personal ("user.<id>") or organization
+// ("organization.<id>") API key using the client credentials grant. The
+// master password is not needed to log in, only to unlock the vault keys
+// afterwards. A new token is requested with the API key when the current one
+// expires.
+func NewAPIKeyAuthClient(ctx context.Context, clientId string, clientSecret string, opts ...Option) (*Client, error) {
+	c, err := NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := "api"
+	if strings.HasPrefix(clientId, "organization.") {
+		scope = "api.organization"
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"scope":         {scope},
+		"client_id":     {clientId},
+		"client_secret": {clientSecret},
+	}
+	tr, err := c.requestToken(ctx, form, nil)
 	if err != nil {
 		return nil, err
 	}
-	ts := config.TokenSource(ctx, tok)
+
+	config := &clientcredentials.Config{
+		ClientID:       clientId,
+		ClientSecret:   clientSecret,
+		TokenURL:       c.tokenURL(),
+		Scopes:         []string{scope},
+		EndpointParams: c.deviceParams(),
+		AuthStyle:      oauth2.AuthStyleInParams,
+	}
+	ctx = c.tokenSourceContext(ctx)
+	ts := oauth2.ReuseTokenSource(tr.token(), config.TokenSource(ctx))
 	c.httpClient = oauth2.NewClient(ctx, ts)
 
 	return c, nil
 }
+
+// makeKey derives the master key of the account using its KDF settings.
+// Servers without prelogin support are assumed to use the legacy settings.
+func (c *Client) makeKey(ctx context.Context, email string, password string) (CryptoKey, error) {
+	kdf, err := c.Account.Prelogin(ctx, email)
+	if errors.Is(err, ErrNotFound) {
+		kdf = legacyKdfConfig
+	} else if err != nil {
+		return CryptoKey{}, err
+	}
+	return MakeKey(password, email, kdf)
+}
+
+func newDeviceIdentifier() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
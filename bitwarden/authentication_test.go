@@ -0,0 +1,199 @@
+package bitwarden
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestIdentityServer accepts the password "password" with the two-factor
+// token "123456" and the API key "user.1234"/"secret". API key tokens expire
+// immediately, the number of API key logins is counted in apiKeyLogins.
+func newTestIdentityServer(t *testing.T) (ts *httptest.Server, apiKeyLogins *int32) {
+	apiKeyLogins = new(int32)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/identity/connect/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Form.Get("deviceIdentifier") == "" || r.Form.Get("deviceType") == "" {
+			t.Errorf("Missing device information: %v", r.Form)
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Form.Get("grant_type") == "client_credentials" {
+			if r.Form.Get("client_id") != "user.1234" || r.Form.Get("client_secret") != "secret" || r.Form.Get("scope") != "api" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"invalid_client"}`))
+				return
+			}
+			atomic.AddInt32(apiKeyLogins, 1)
+			w.Write([]byte(`{"access_token":"access","token_type":"Bearer","expires_in":1}`))
+			return
+		}
+
+		switch r.Form.Get("twoFactorToken") {
+		case "":
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":               "invalid_grant",
+				"error_description":   "Two factor required.",
+				"TwoFactorProviders":  []string{"1", "0"},
+				"TwoFactorProviders2": map[string]interface{}{"1": map[string]string{"Email": "t***@example.com"}, "0": nil},
+			})
+		case "123456":
+			if r.Form.Get("twoFactorProvider") != "0" || r.Form.Get("twoFactorRemember") != "1" {
+				t.Errorf("Unexpected two-factor parameters: %v", r.Form)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token":   "access",
+				"token_type":     "Bearer",
+				"expires_in":     3600,
+				"refresh_token":  "refresh",
+				"TwoFactorToken": "remember-me",
+			})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant","ErrorModel":{"Message":"Two-step token is invalid. Try again."}}`))
+		}
+	})
+	mux.HandleFunc("/identity/accounts/prelogin", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"kdf":0,"kdfIterations":5000,"kdfMemory":null,"kdfParallelism":null}`))
+	})
+	mux.HandleFunc("/api/two-factor/send-email-login", func(w http.ResponseWriter, r *http.Request) {
+		var req TwoFactorEmailRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mk, err := MakeKey("password", "test@example.com", legacyKdfConfig)
+		if err != nil {
+			t.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if req.Email != "test@example.com" || req.MasterPasswordHash != HashPassword("password", mk) || req.DeviceIdentifier == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message":"Username or password is incorrect. Try again."}`))
+		}
+	})
+	mux.HandleFunc("/api/accounts/profile", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"email":"test@example.com"}`))
+	})
+
+	ts = httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts, apiKeyLogins
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	ts, _ := newTestIdentityServer(t)
+	ctx := context.Background()
+	opts := []Option{WithServerURL(ts.URL), WithHTTPClient(ts.Client())}
+
+	_, err := NewUserPasswordAuthClient(ctx, "test@example.com", "password", opts...)
+	if !errors.Is(err, ErrTwoFactorRequired) {
+		t.Fatalf("Expected ErrTwoFactorRequired got %v", err)
+	}
+	var tfe *TwoFactorRequiredError
+	if !errors.As(err, &tfe) {
+		t.Fatalf("Expected *TwoFactorRequiredError got %T", err)
+	}
+	expected := []TwoFactorProvider{TwoFactorProvider_Authenticator, TwoFactorProvider_Email}
+	if !reflect.DeepEqual(tfe.Providers, expected) {
+		t.Errorf("Expected %v got %v", expected, tfe.Providers)
+	}
+	if string(tfe.ProviderData[TwoFactorProvider_Email]) != `{"Email":"t***@example.com"}` {
+		t.Errorf("Unexpected provider data %s", tfe.ProviderData[TwoFactorProvider_Email])
+	}
+
+	if err := SendEmailLoginCode(ctx, " Test@example.com", "password", opts...); err != nil {
+		t.Errorf("Expected email code to be sent: %v", err)
+	}
+	if err := SendEmailLoginCode(ctx, "test@example.com", "wrong", opts...); err == nil {
+		t.Error("Expected error for wrong password")
+	}
+
+	_, err = NewUserPasswordAuthClient(ctx, "test@example.com", "password", append(opts, WithTwoFactor(TwoFactorProvider_Authenticator, "000000", false))...)
+	if err == nil || errors.Is(err, ErrTwoFactorRequired) {
+		t.Errorf("Expected invalid token error got %v", err)
+	}
+
+	c, err := NewUserPasswordAuthClient(ctx, "test@example.com", "password", append(opts, WithTwoFactor(TwoFactorProvider_Authenticator, "123456", true))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.TwoFactorRememberToken != "remember-me" {
+		t.Errorf("Expected remember token got %q", c.TwoFactorRememberToken)
+	}
+
+	account, err := c.Account.GetProfile(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Email != "test@example.com" {
+		t.Errorf("Expected test@example.com got %v", account.Email)
+	}
+}
+
+func TestAPIKeyLogin(t *testing.T) {
+	ts, logins := newTestIdentityServer(t)
+	ctx := context.Background()
+	opts := []Option{WithServerURL(ts.URL), WithHTTPClient(ts.Client())}
+
+	_, err := NewAPIKeyAuthClient(ctx, "user.1234", "wrong", opts...)
+	if err == nil {
+		t.Error("Expected error for invalid client secret")
+	}
+
+	c, err := NewAPIKeyAuthClient(ctx, "user.1234", "secret", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *logins != 1 {
+		t.Errorf("Expected 1 login got %d", *logins)
+	}
+
+	// The token has already expired, so a new one is requested.
+	_, err = c.Account.GetProfile(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *logins != 2 {
+		t.Errorf("Expected 2 logins got %d", *logins)
+	}
+}
+
+func TestPrelogin(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/identity/accounts/prelogin" || body["email"] != "test@example.com" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"kdf":1,"kdfIterations":3,"kdfMemory":64,"kdfParallelism":4}`))
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(WithServerURL(ts.URL), WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kdf, err := c.Account.Prelogin(context.Background(), "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := KdfConfig{Type: KdfType_Argon2id, Iterations: 3, Memory: 64, Parallelism: 4}
+	if kdf != expected {
+		t.Errorf("Expected %+v got %+v", expected, kdf)
+	}
+}
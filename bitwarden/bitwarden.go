@@ -2,20 +2,19 @@ package bitwarden
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 )
 
 const (
-	//defaultAPIBaseURL      = "https://api.bitwarden.com/"
-	//defaultIdentityBaseURL = "https://identity.bitwarden.com/"
-	defaultAPIBaseURL      = "http://localhost:8080/api/"
-	defaultIdentityBaseURL = "http://localhost:8080/identity/"
+	defaultAPIBaseURL      = "https://api.bitwarden.com/"
+	defaultIdentityBaseURL = "https://identity.bitwarden.com/"
 	defaultWebVaultBaseURL = "https://vault.bitwarden.com/"
-	defaultIconsBaseURL    = "https://icons.bitwarden.com/"
+	defaultIconsBaseURL    = "https://icons.bitwarden.net/"
 	apiVersion             = "0.0.1"
 	defaultUserAgent       = "go-bitwarden/" + apiVersion
 )
@@ -30,54 +29,93 @@ type Client struct {
 	// UserAgent used when communicating with the Bitwarden API.
 	UserAgent string
 
+	// DeviceIdentifier sent to the identity server on login.
+	DeviceIdentifier string
+
+	// TwoFactorRememberToken is set after a login using WithTwoFactor with
+	// remember enabled.
+	TwoFactorRememberToken string
+
 	// HttpClient is the underlying HTTP client
 	// used to communicate with the API.
 	httpClient *http.Client
 
+	// baseClient is httpClient before authentication, used for requests
+	// that must not carry the access token, like attachment downloads from
+	// a storage host.
+	baseClient *http.Client
+
 	common service // Reuse a single struct instead of allocating one for each service on the heap.
 
 	// Services
-	Cipher  *CipherService
-	Folder  *FolderService
-	Account *AccountService
-	Sync    *SyncService
+	Cipher       *CipherService
+	Folder       *FolderService
+	Account      *AccountService
+	Sync         *SyncService
+	Organization *OrganizationService
+	Collection   *CollectionService
+	Attachment   *AttachmentService
+	Send         *SendService
 
 	// Set to true to output debugging logs during API calls
 	Debug bool
+
+	logger      *log.Logger
+	retryPolicy RetryPolicy
+	twoFactor   *twoFactor
 }
 
 type service struct {
 	client *Client
 }
 
-func NewClient(httpClient *http.Client) *Client {
-	if httpClient == nil {
-		httpClient = http.DefaultClient
-	}
-
+// NewClient returns a client for the official bitwarden.com servers unless
+// configured otherwise through opts.
+func NewClient(opts ...Option) (*Client, error) {
 	apiBaseURL, _ := url.Parse(defaultAPIBaseURL)
 	identityBaseURL, _ := url.Parse(defaultIdentityBaseURL)
 	webVaultBaseURL, _ := url.Parse(defaultWebVaultBaseURL)
 	iconsBaseURL, _ := url.Parse(defaultIconsBaseURL)
 
-	c := &Client{httpClient: httpClient,
-		APIBaseURL:      apiBaseURL,
-		IdentityBaseURL: identityBaseURL,
-		WebVaultBaseURL: webVaultBaseURL,
-		IconsBaseURL:    iconsBaseURL,
+	c := &Client{httpClient: http.DefaultClient,
+		APIBaseURL:       apiBaseURL,
+		IdentityBaseURL:  identityBaseURL,
+		WebVaultBaseURL:  webVaultBaseURL,
+		IconsBaseURL:     iconsBaseURL,
+		UserAgent:        defaultUserAgent,
+		DeviceIdentifier: newDeviceIdentifier(),
+		retryPolicy:      DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
 	}
+
+	c.baseClient = c.httpClient
+
 	c.common.client = c
 	c.Cipher = (*CipherService)(&c.common)
 	c.Folder = (*FolderService)(&c.common)
 	c.Account = (*AccountService)(&c.common)
 	c.Sync = (*SyncService)(&c.common)
+	c.Organization = (*OrganizationService)(&c.common)
+	c.Collection = (*CollectionService)(&c.common)
+	c.Attachment = (*AttachmentService)(&c.common)
+	c.Send = (*SendService)(&c.common)
+
+	return c, nil
+}
 
-	return c
+func (c *Client) newRequest(ctx context.Context, method, path string, body interface{}) (*http.Request, error) {
+	return c.newBaseRequest(ctx, c.APIBaseURL, method, path, body)
 }
 
-func (c *Client) newRequest(method, path string, body interface{}) (*http.Request, error) {
+// newBaseRequest is like newRequest for endpoints outside of the API, such as
+// the identity server.
+func (c *Client) newBaseRequest(ctx context.Context, base *url.URL, method, path string, body interface{}) (*http.Request, error) {
 	rel := &url.URL{Path: path}
-	u := c.APIBaseURL.ResolveReference(rel)
+	u := base.ResolveReference(rel)
 	var buf io.ReadWriter
 	if body != nil {
 		buf = new(bytes.Buffer)
@@ -86,7 +124,7 @@ func (c *Client) newRequest(method, path string, body interface{}) (*http.Reques
 			return nil, err
 		}
 	}
-	req, err := http.NewRequest(method, u.String(), buf)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), buf)
 	if err != nil {
 		return nil, err
 	}
@@ -98,46 +136,35 @@ func (c *Client) newRequest(method, path string, body interface{}) (*http.Reques
 	return req, nil
 }
 
-type HttpErrorResponse struct {
-	HttpResponse *http.Response
-	ErrorResponse
-}
-
-// Error implements the error interface.
-func (r *HttpErrorResponse) Error() string {
-	return fmt.Sprintf("%v %v: %v %v",
-		r.HttpResponse.Request.Method, r.HttpResponse.Request.URL,
-		r.HttpResponse.StatusCode, r.Message)
-}
-
-func CheckResponse(resp *http.Response) error {
-	if code := resp.StatusCode; 200 <= code && code <= 299 {
-		return nil
-	}
-
-	errorResponse := &HttpErrorResponse{}
-	errorResponse.HttpResponse = resp
-
-	err := json.NewDecoder(resp.Body).Decode(errorResponse)
-	if err != nil {
-		return err
-	}
-
-	return errorResponse
-}
-
 func (c *Client) do(req *http.Request, v interface{}) (*http.Response, error) {
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.send(req)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	err = CheckResponse(resp)
 	if err != nil {
 		return resp, err
 	}
 
-	defer resp.Body.Close()
+	if v == nil {
+		return resp, nil
+	}
 	err = json.NewDecoder(resp.Body).Decode(v)
+	if err == io.EOF {
+		err = nil // empty response body
+	}
 	return resp, err
 }
+
+func (c *Client) debugf(format string, v ...interface{}) {
+	if !c.Debug {
+		return
+	}
+	l := c.logger
+	if l == nil {
+		l = log.Default()
+	}
+	l.Printf(format, v...)
+}
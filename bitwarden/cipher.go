@@ -1,11 +1,19 @@
 package bitwarden
 
+import (
+	"context"
+	"errors"
+)
+
 type CipherService struct {
 	client *Client
 }
 
-func (c *CipherService) ListCiphers() ([]Cipher, error) {
-	req, err := c.client.newRequest("GET", "ciphers", nil)
+func (c *CipherService) ListCiphers(ctx context.Context) ([]EncryptedCipher, error) {
+	req, err := c.client.newRequest(ctx, "GET", "ciphers", nil)
+	if err != nil {
+		return nil, err
+	}
 
 	cir := make([]CipherDetailsResponse, 0)
 	data := List{Data: &cir}
@@ -14,60 +22,256 @@ func (c *CipherService) ListCiphers() ([]Cipher, error) {
 		return nil, err
 	}
 
-	ci := make([]Cipher, len(cir))
+	ci := make([]EncryptedCipher, len(cir))
 	for i, c := range cir {
-		ci[i] = c.ToCipher()
+		if ci[i], err = c.ToCipher(); err != nil {
+			return nil, err
+		}
 	}
-	return ci, err
+	return ci, nil
 }
 
-func (c *CipherService) AddCipher(cipher *Cipher) (*Cipher, error) {
-	creq := CipherRequest{}
-	err := creq.FromCipher(*cipher)
+// GetCipher returns a cipher by id. An error matching ErrNotFound is
+// returned if it doesn't exist.
+func (c *CipherService) GetCipher(ctx context.Context, id string) (*EncryptedCipher, error) {
+	req, err := c.client.newRequest(ctx, "GET", "ciphers/"+id, nil)
 	if err != nil {
 		return nil, err
 	}
-	req, err := c.client.newRequest("POST", "ciphers", creq)
 
 	cres := CipherResponse{}
 	_, err = c.client.do(req, &cres)
 	if err != nil {
 		return nil, err
 	}
-	ci := cres.ToCipher()
-	return &ci, err
+	ci, err := cres.ToCipher()
+	if err != nil {
+		return nil, err
+	}
+	return &ci, nil
+}
+
+// GetCipherDetails is like GetCipher, including the collections the cipher
+// is assigned to.
+func (c *CipherService) GetCipherDetails(ctx context.Context, id string) (*EncryptedCipher, error) {
+	req, err := c.client.newRequest(ctx, "GET", "ciphers/"+id+"/details", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	cres := CipherDetailsResponse{}
+	_, err = c.client.do(req, &cres)
+	if err != nil {
+		return nil, err
+	}
+	ci, err := cres.ToCipher()
+	if err != nil {
+		return nil, err
+	}
+	return &ci, nil
 }
 
-func (c *CipherService) UpdateCipher(cipher *Cipher) (*Cipher, error) {
+func (c *CipherService) AddCipher(ctx context.Context, cipher *EncryptedCipher) (*EncryptedCipher, error) {
 	creq := CipherRequest{}
 	err := creq.FromCipher(*cipher)
 	if err != nil {
 		return nil, err
 	}
-	req, err := c.client.newRequest("PUT", "ciphers/"+cipher.Id, creq)
+	// Organization ciphers have to be created in at least one collection.
+	var body interface{} = creq
+	path := "ciphers"
+	if cipher.OrganizationId != nil {
+		body = CipherCreateRequest{Cipher: creq, CollectionIds: cipher.CollectionIds}
+		path = "ciphers/create"
+	}
+	req, err := c.client.newRequest(ctx, "POST", path, body)
+	if err != nil {
+		return nil, err
+	}
 
 	cres := CipherResponse{}
 	_, err = c.client.do(req, &cres)
 	if err != nil {
 		return nil, err
 	}
-	ci := cres.ToCipher()
+	ci, err := cres.ToCipher()
+	if err != nil {
+		return nil, err
+	}
 	return &ci, nil
 }
 
-func (c *CipherService) DeleteCipher(cipher *Cipher) (*Cipher, error) {
+func (c *CipherService) UpdateCipher(ctx context.Context, cipher *EncryptedCipher) (*EncryptedCipher, error) {
 	creq := CipherRequest{}
 	err := creq.FromCipher(*cipher)
 	if err != nil {
 		return nil, err
 	}
-	req, err := c.client.newRequest("DELETE", "ciphers/"+cipher.Id, creq)
+	req, err := c.client.newRequest(ctx, "PUT", "ciphers/"+cipher.Id, creq)
+	if err != nil {
+		return nil, err
+	}
+
+	cres := CipherResponse{}
+	_, err = c.client.do(req, &cres)
+	if err != nil {
+		return nil, err
+	}
+	ci, err := cres.ToCipher()
+	if err != nil {
+		return nil, err
+	}
+	return &ci, nil
+}
+
+// DeleteCipher moves a cipher to the trash, from where it can be restored
+// until the server purges it.
+func (c *CipherService) DeleteCipher(ctx context.Context, id string) error {
+	req, err := c.client.newRequest(ctx, "PUT", "ciphers/"+id+"/delete", nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.do(req, nil)
+	return err
+}
+
+// DeleteCiphers moves several ciphers to the trash.
+func (c *CipherService) DeleteCiphers(ctx context.Context, ids []string) error {
+	req, err := c.client.newRequest(ctx, "PUT", "ciphers/delete", CipherBulkRequest{Ids: ids})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.do(req, nil)
+	return err
+}
+
+// RestoreCipher restores a cipher from the trash.
+func (c *CipherService) RestoreCipher(ctx context.Context, id string) (*EncryptedCipher, error) {
+	req, err := c.client.newRequest(ctx, "PUT", "ciphers/"+id+"/restore", nil)
+	if err != nil {
+		return nil, err
+	}
 
 	cres := CipherResponse{}
 	_, err = c.client.do(req, &cres)
 	if err != nil {
 		return nil, err
 	}
-	ci := cres.ToCipher()
+	ci, err := cres.ToCipher()
+	if err != nil {
+		return nil, err
+	}
 	return &ci, nil
 }
+
+// RestoreCiphers restores several ciphers from the trash.
+func (c *CipherService) RestoreCiphers(ctx context.Context, ids []string) ([]EncryptedCipher, error) {
+	req, err := c.client.newRequest(ctx, "PUT", "ciphers/restore", CipherBulkRequest{Ids: ids})
+	if err != nil {
+		return nil, err
+	}
+
+	cir := make([]CipherMiniResponse, 0)
+	data := List{Data: &cir}
+	_, err = c.client.do(req, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	ci := make([]EncryptedCipher, len(cir))
+	for i, c := range cir {
+		if ci[i], err = c.ToCipher(); err != nil {
+			return nil, err
+		}
+	}
+	return ci, nil
+}
+
+// PurgeCipher deletes a cipher permanently, whether it is in the trash or
+// not.
+func (c *CipherService) PurgeCipher(ctx context.Context, id string) error {
+	req, err := c.client.newRequest(ctx, "DELETE", "ciphers/"+id, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.do(req, nil)
+	return err
+}
+
+// PurgeCiphers deletes several ciphers permanently.
+func (c *CipherService) PurgeCiphers(ctx context.Context, ids []string) error {
+	req, err := c.client.newRequest(ctx, "DELETE", "ciphers", CipherBulkRequest{Ids: ids})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.do(req, nil)
+	return err
+}
+
+// MoveCiphers moves several ciphers into a folder, or out of any folder if
+// folderId is nil.
+func (c *CipherService) MoveCiphers(ctx context.Context, ids []string, folderId *string) error {
+	req, err := c.client.newRequest(ctx, "PUT", "ciphers/move", CipherBulkMoveRequest{Ids: ids, FolderId: folderId})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.do(req, nil)
+	return err
+}
+
+// ShareCiphers moves several personal ciphers into an organization and
+// assigns them to collectionIds. The ciphers must have been prepared with
+// CipherView.MoveToOrganization and encrypted.
+func (c *CipherService) ShareCiphers(ctx context.Context, ciphers []EncryptedCipher, collectionIds []string) ([]EncryptedCipher, error) {
+	sreq := CipherBulkShareRequest{Ciphers: make([]CipherWithIdRequest, len(ciphers)), CollectionIds: collectionIds}
+	for i, cipher := range ciphers {
+		if cipher.Id == "" || cipher.OrganizationId == nil {
+			return nil, errors.New("bitwarden: shared ciphers need an id and an organization")
+		}
+		sreq.Ciphers[i].Id = cipher.Id
+		if err := sreq.Ciphers[i].FromCipher(cipher); err != nil {
+			return nil, err
+		}
+	}
+	req, err := c.client.newRequest(ctx, "PUT", "ciphers/share", sreq)
+	if err != nil {
+		return nil, err
+	}
+
+	// Older servers answer without content.
+	cir := make([]CipherMiniResponse, 0)
+	data := List{Data: &cir}
+	_, err = c.client.do(req, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	ci := make([]EncryptedCipher, len(cir))
+	for i, c := range cir {
+		if ci[i], err = c.ToCipher(); err != nil {
+			return nil, err
+		}
+	}
+	return ci, nil
+}
+
+// UpdateCipherCollections replaces the collections an organization cipher
+// is assigned to.
+func (c *CipherService) UpdateCipherCollections(ctx context.Context, id string, collectionIds []string) error {
+	if collectionIds == nil {
+		collectionIds = []string{}
+	}
+	creq := CipherCollectionsRequest{CollectionIds: collectionIds}
+	req, err := c.client.newRequest(ctx, "PUT", "ciphers/"+id+"/collections", creq)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.do(req, nil)
+	return err
+}
@@ -0,0 +1,241 @@
+package bitwarden
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCipherTrash(t *testing.T) {
+	var calls []string
+	var bulk CipherBulkRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ciphers/", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if r.ContentLength > 0 {
+			json.NewDecoder(r.Body).Decode(&bulk)
+		}
+		switch r.URL.Path {
+		case "/ciphers/c1/restore":
+			w.Write([]byte(`{"id":"c1","type":2,"data":{"name":"2.aXY=|Y3Q=|bWFj"},"deletedDate":null,"object":"cipher"}`))
+		case "/ciphers/restore":
+			w.Write([]byte(`{"data":[{"id":"c1","type":2,"data":{}},{"id":"c2","type":2,"data":{}}],"object":"list"}`))
+		}
+		// Everything else answers with an empty 200.
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	c, err := NewClient(WithAPIURL(ts.URL), WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := c.Cipher.DeleteCipher(ctx, "c1"); err != nil {
+		t.Fatal(err)
+	}
+	ci, err := c.Cipher.RestoreCipher(ctx, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.Id != "c1" || ci.DeletedDate != nil {
+		t.Errorf("Unexpected cipher %+v", ci)
+	}
+	if err := c.Cipher.DeleteCiphers(ctx, []string{"c1", "c2"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bulk.Ids, []string{"c1", "c2"}) {
+		t.Errorf("Unexpected ids %v", bulk.Ids)
+	}
+	cis, err := c.Cipher.RestoreCiphers(ctx, []string{"c1", "c2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cis) != 2 {
+		t.Errorf("Expected 2 restored ciphers got %d", len(cis))
+	}
+	if err := c.Cipher.PurgeCipher(ctx, "c1"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"PUT /ciphers/c1/delete",
+		"PUT /ciphers/c1/restore",
+		"PUT /ciphers/delete",
+		"PUT /ciphers/restore",
+		"DELETE /ciphers/c1",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected calls %v got %v", expected, calls)
+	}
+}
+
+func TestCipherDeletedDate(t *testing.T) {
+	cdr := CipherDetailsResponse{}
+	err := json.Unmarshal([]byte(`{"id":"c1","type":2,"data":{},"deletedDate":"2024-05-01T10:00:00.123Z","collectionIds":[]}`), &cdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ci, err := cdr.ToCipher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.DeletedDate == nil || ci.DeletedDate.Year() != 2024 {
+		t.Fatalf("Unexpected deleted date %v", ci.DeletedDate)
+	}
+	if d := NewCipherDetailsResponse(ci).DeletedDate; d == nil || !d.Equal(ci.DeletedDate.Time) {
+		t.Errorf("Deleted date not carried over: %v", d)
+	}
+}
+
+func TestCipherBulk(t *testing.T) {
+	var move CipherBulkMoveRequest
+	var share map[string]interface{}
+	var purge CipherBulkRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ciphers/move", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&move)
+	})
+	mux.HandleFunc("/ciphers/share", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&share)
+		w.Write([]byte(`{"data":[{"id":"c1","organizationId":"org1","type":1,"data":{}}],"object":"list"}`))
+	})
+	mux.HandleFunc("/ciphers", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			t.Errorf("Unexpected method %s", r.Method)
+		}
+		json.NewDecoder(r.Body).Decode(&purge)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	c, err := NewClient(WithAPIURL(ts.URL), WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	folderId := "f1"
+	if err := c.Cipher.MoveCiphers(ctx, []string{"c1", "c2"}, &folderId); err != nil {
+		t.Fatal(err)
+	}
+	if len(move.Ids) != 2 || move.FolderId == nil || *move.FolderId != "f1" {
+		t.Errorf("Unexpected move request %+v", move)
+	}
+
+	orgId := "org1"
+	if _, err := c.Cipher.ShareCiphers(ctx, []EncryptedCipher{{Id: "c1", Type: CipherType_Login, Login: &EncryptedLoginData{}}}, nil); err == nil {
+		t.Error("Expected error for cipher without organization")
+	}
+	cis, err := c.Cipher.ShareCiphers(ctx, []EncryptedCipher{{Id: "c1", Type: CipherType_Login, OrganizationId: &orgId, Login: &EncryptedLoginData{}}}, []string{"col1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cis) != 1 || *cis[0].OrganizationId != "org1" {
+		t.Errorf("Unexpected shared ciphers %+v", cis)
+	}
+	sc := share["Ciphers"].([]interface{})[0].(map[string]interface{})
+	if sc["Id"] != "c1" || sc["OrganizationId"] != "org1" || share["CollectionIds"].([]interface{})[0] != "col1" {
+		t.Errorf("Unexpected share request %v", share)
+	}
+
+	if err := c.Cipher.PurgeCiphers(ctx, []string{"c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(purge.Ids) != 1 {
+		t.Errorf("Unexpected purge request %+v", purge)
+	}
+}
+
+func TestGetCipherAndFolder(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ciphers/c1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"c1","folderId":"f1","type":2,"data":{},"favorite":true,"object":"cipher"}`))
+	})
+	mux.HandleFunc("/ciphers/c1/details", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"c1","organizationId":"org1","type":2,"data":{},"collectionIds":["col1"],"object":"cipherDetails"}`))
+	})
+	mux.HandleFunc("/folders/f1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"f1","name":"2.aXY=|Y3Q=|bWFj","object":"folder"}`))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"Resource not found.","object":"error"}`))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	c, err := NewClient(WithAPIURL(ts.URL), WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	ci, err := c.Cipher.GetCipher(ctx, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.Id != "c1" || ci.FolderId == nil || *ci.FolderId != "f1" || !ci.Favorite || ci.SecureNote == nil {
+		t.Errorf("Unexpected cipher %+v", ci)
+	}
+	ci, err = c.Cipher.GetCipherDetails(ctx, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ci.CollectionIds, []string{"col1"}) {
+		t.Errorf("Unexpected collection ids %v", ci.CollectionIds)
+	}
+	f, err := c.Folder.GetFolder(ctx, "f1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Id != "f1" || f.Name.IsZero() {
+		t.Errorf("Unexpected folder %+v", f)
+	}
+
+	if _, err := c.Cipher.GetCipher(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound got %v", err)
+	}
+	if _, err := c.Folder.GetFolder(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound got %v", err)
+	}
+}
+
+func TestListCiphersUnknownEncryptionType(t *testing.T) {
+	key := testRandomKey(t)
+	name, err := Encrypt([]byte("Item"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[{"id":"c1","type":2,"data":{"name":"` + name.String() + `"}},` +
+			`{"id":"c2","type":2,"key":"7.AAAA","data":{"name":"` + name.String() + `","notes":"9.x|y"}}],"object":"list"}`))
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(WithAPIURL(ts.URL), WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphers, err := c.Cipher.ListCiphers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ciphers) != 2 {
+		t.Fatalf("Expected 2 ciphers got %d", len(ciphers))
+	}
+	if v, err := ciphers[0].Decrypt(key); err != nil || *v.SecureNote.Name != "Item" {
+		t.Errorf("Unexpected cipher: %v", err)
+	}
+	if _, err := ciphers[1].Decrypt(key); err == nil {
+		t.Error("Expected error for unknown item key type")
+	}
+	if ciphers[1].Key.String() != "7.AAAA" || ciphers[1].SecureNote.Notes.String() != "9.x|y" {
+		t.Errorf("Expected unknown cipher strings to be kept, got %v", ciphers[1])
+	}
+}
@@ -0,0 +1,89 @@
+package bitwarden
+
+import "context"
+
+const (
+	PATH_COLLECTIONS = "collections"
+)
+
+type CollectionService struct {
+	client *Client
+}
+
+// ListCollections lists the collections the user can access across all of
+// their organizations.
+func (c *CollectionService) ListCollections(ctx context.Context) ([]EncryptedCollection, error) {
+	return c.list(ctx, PATH_COLLECTIONS)
+}
+
+// ListOrganizationCollections lists all collections of an organization.
+// It requires the permission to manage collections.
+func (c *CollectionService) ListOrganizationCollections(ctx context.Context, organizationId string) ([]EncryptedCollection, error) {
+	return c.list(ctx, collectionsPath(organizationId))
+}
+
+func (c *CollectionService) list(ctx context.Context, path string) ([]EncryptedCollection, error) {
+	req, err := c.client.newRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	collections := make([]EncryptedCollection, 0)
+	data := List{Data: &collections}
+	_, err = c.client.do(req, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+// AddCollection creates a collection in collection.OrganizationId, see
+// CollectionView.Encrypt.
+func (c *CollectionService) AddCollection(ctx context.Context, collection *EncryptedCollection) (*EncryptedCollection, error) {
+	creq := CollectionRequest{Name: collection.Name, ExternalId: collection.ExternalId}
+	req, err := c.client.newRequest(ctx, "POST", collectionsPath(collection.OrganizationId), creq)
+	if err != nil {
+		return nil, err
+	}
+
+	col := EncryptedCollection{}
+	_, err = c.client.do(req, &col)
+	if err != nil {
+		return nil, err
+	}
+
+	return &col, nil
+}
+
+// UpdateCollection renames a collection.
+func (c *CollectionService) UpdateCollection(ctx context.Context, collection *EncryptedCollection) (*EncryptedCollection, error) {
+	creq := CollectionRequest{Name: collection.Name, ExternalId: collection.ExternalId}
+	req, err := c.client.newRequest(ctx, "PUT", collectionsPath(collection.OrganizationId)+"/"+collection.Id, creq)
+	if err != nil {
+		return nil, err
+	}
+
+	col := EncryptedCollection{}
+	_, err = c.client.do(req, &col)
+	if err != nil {
+		return nil, err
+	}
+
+	return &col, nil
+}
+
+// DeleteCollection deletes a collection. Ciphers in it are kept.
+func (c *CollectionService) DeleteCollection(ctx context.Context, organizationId, id string) error {
+	req, err := c.client.newRequest(ctx, "DELETE", collectionsPath(organizationId)+"/"+id, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.do(req, nil)
+	return err
+}
+
+func collectionsPath(organizationId string) string {
+	return PATH_ORGANIZATIONS + "/" + organizationId + "/" + PATH_COLLECTIONS
+}
@@ -0,0 +1,106 @@
+package bitwarden
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCollectionService(t *testing.T) {
+	_, orgKey := testKeys(t)
+	kr := &KeyRing{OrganizationKeys: map[string]CryptoKey{"org1": orgKey}}
+
+	var created CollectionRequest
+	var assigned CipherCollectionsRequest
+	var createdCipher CipherCreateRequest
+	deleted := false
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/collections", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[{"id":"col1","organizationId":"org1","name":"` + created.Name.String() + `","readOnly":true,"object":"collectionDetails"}],"object":"list"}`))
+	})
+	mux.HandleFunc("/organizations/org1/collections", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Unexpected method %s", r.Method)
+		}
+		json.NewDecoder(r.Body).Decode(&created)
+		w.Write([]byte(`{"id":"col1","organizationId":"org1","name":"` + created.Name.String() + `","object":"collection"}`))
+	})
+	mux.HandleFunc("/organizations/org1/collections/col1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			t.Errorf("Unexpected method %s", r.Method)
+		}
+		deleted = true
+	})
+	mux.HandleFunc("/ciphers/create", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&createdCipher)
+		w.Write([]byte(`{"id":"c1","organizationId":"org1","type":1,"object":"cipher"}`))
+	})
+	mux.HandleFunc("/ciphers/c1/collections", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" {
+			t.Errorf("Unexpected method %s", r.Method)
+		}
+		json.NewDecoder(r.Body).Decode(&assigned)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	c, err := NewClient(WithAPIURL(ts.URL), WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	col := CollectionView{OrganizationId: "org1", Name: "Shared"}
+	ecol, err := col.Encrypt(kr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Collection.AddCollection(ctx, ecol); err != nil {
+		t.Fatal(err)
+	}
+	if created.Name.IsZero() || created.Name.String() != ecol.Name.String() {
+		t.Errorf("Expected encrypted name got %q", created.Name)
+	}
+
+	cols, err := c.Collection.ListCollections(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cols) != 1 || !cols[0].ReadOnly {
+		t.Fatalf("Unexpected collections %+v", cols)
+	}
+	dcol, err := cols[0].Decrypt(kr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dcol.Name != "Shared" || !dcol.ReadOnly {
+		t.Errorf("Unexpected collection %+v", dcol)
+	}
+
+	orgId := "org1"
+	ciph := EncryptedCipher{Type: CipherType_Login, OrganizationId: &orgId, CollectionIds: []string{"col1"}, Login: &EncryptedLoginData{}}
+	if _, err := c.Cipher.AddCipher(ctx, &ciph); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(createdCipher.CollectionIds, []string{"col1"}) {
+		t.Errorf("Unexpected collection ids %v", createdCipher.CollectionIds)
+	}
+
+	if err := c.Cipher.UpdateCipherCollections(ctx, "c1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if assigned.CollectionIds == nil || len(assigned.CollectionIds) != 0 {
+		t.Errorf("Expected empty collection ids got %v", assigned.CollectionIds)
+	}
+
+	if err := c.Collection.DeleteCollection(ctx, "org1", "col1"); err != nil {
+		t.Fatal(err)
+	}
+	if !deleted {
+		t.Error("Expected collection to be deleted")
+	}
+}
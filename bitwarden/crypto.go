@@ -5,25 +5,41 @@ import (
 	"crypto/cipher"
 	"crypto/hmac"
 	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"math/big"
 	"strconv"
 	"strings"
 
 	"github.com/andreburgaud/crypt2go/padding"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
 	"golang.org/x/crypto/pbkdf2"
 )
 
+// CipherString is an encrypted value in the format used by Bitwarden:
+// "<type>.<iv>|<ct>|<mac>" with the pieces base64 encoded and present as the
+// encryption type requires. The zero value is an empty cipher string, which
+// is marshalled as an empty string and null in JSON.
+//
+// Unmarshalling doesn't fail for cipher strings that can't be parsed, for
+// example ones of newer encryption types. They are kept as is and marshalled
+// unchanged, but fail to decrypt, see Err.
 type CipherString struct {
-	encryptedString      string
-	encryptionType       int
-	decryptedValue       string
-	cipherText           string
-	initializationVector string
-	mac                  string
+	encryptionType int
+	iv             []byte
+	ct             []byte
+	mac            []byte
+
+	raw string
+	err error
 }
 
 type CryptoKey struct {
@@ -42,75 +58,237 @@ const (
 	Rsa2048_OaepSha1_HmacSha256_B64   = iota
 )
 
+type KdfType int
+
+const (
+	KdfType_PBKDF2_SHA256 KdfType = 0
+	KdfType_Argon2id      KdfType = 1
+)
+
+const (
+	minPBKDF2Iterations  = 5000
+	minArgon2Iterations  = 2
+	minArgon2Memory      = 15   // MiB
+	maxArgon2Memory      = 1024 // MiB
+	maxArgon2Parallelism = 16
+
+	sendPasswordIterations = 100000
+)
+
+// KdfConfig holds the key derivation settings of an account.
+type KdfConfig struct {
+	Type       KdfType `json:"kdf"`
+	Iterations int     `json:"kdfIterations"`
+	// Memory in MiB, Argon2id only
+	Memory int `json:"kdfMemory"`
+	// Argon2id only
+	Parallelism int `json:"kdfParallelism"`
+}
+
+// DefaultKdfConfig is used for new accounts by the official clients.
+var DefaultKdfConfig = KdfConfig{Type: KdfType_PBKDF2_SHA256, Iterations: 600000}
+
+// legacyKdfConfig was used by all accounts before the KDF became
+// configurable, it is assumed for servers that don't support prelogin.
+var legacyKdfConfig = KdfConfig{Type: KdfType_PBKDF2_SHA256, Iterations: 5000}
+
 func NewCryptoKey(key []byte, encryptionType int) (CryptoKey, error) {
 	c := CryptoKey{EncryptionType: encryptionType}
 
 	switch encryptionType {
 	case AesCbc256_B64:
 		c.EncKey = key
+	case AesCbc128_HmacSha256_B64:
+		if len(key) != 32 {
+			return c, fmt.Errorf("Invalid key size: %d", len(key))
+		}
+		c.EncKey = key[:16]
+		c.MacKey = key[16:]
 	case AesCbc256_HmacSha256_B64:
+		if len(key) != 64 {
+			return c, fmt.Errorf("Invalid key size: %d", len(key))
+		}
 		c.EncKey = key[:32]
 		c.MacKey = key[32:]
 	default:
 		return c, fmt.Errorf("Invalid encryption type: %d", encryptionType)
 	}
 
-	if len(key) != (len(c.EncKey) + len(c.MacKey)) {
-		return c, fmt.Errorf("Invalid key size: %d", len(key))
-	}
-
 	return c, nil
 }
 
-func NewCipherString(encryptedString string) (*CipherString, error) {
-	cs := CipherString{}
-	cs.encryptedString = encryptedString
+// NewCipherString parses a cipher string. Legacy cipher strings without the
+// type header are AesCbc128_HmacSha256_B64 if they have a MAC and
+// AesCbc256_B64 otherwise.
+func NewCipherString(encryptedString string) (CipherString, error) {
+	var cs CipherString
 	if encryptedString == "" {
-		return nil, errors.New("empty key")
+		return CipherString{}, errors.New("empty cipher string")
 	}
-	headerPieces := strings.Split(cs.encryptedString, ".")
-	var encPieces []string
-	if len(headerPieces) == 2 {
-		cs.encryptionType, _ = strconv.Atoi(headerPieces[0])
-		encPieces = strings.Split(headerPieces[1], "|")
+
+	body := encryptedString
+	if header, rest, ok := strings.Cut(encryptedString, "."); ok {
+		t, err := strconv.Atoi(header)
+		if err != nil {
+			return CipherString{}, fmt.Errorf("invalid cipher string header %q", header)
+		}
+		cs.encryptionType = t
+		body = rest
+	} else if strings.Count(body, "|") == 2 {
+		cs.encryptionType = AesCbc128_HmacSha256_B64
 	} else {
-		return nil, errors.New("invalid key header")
+		cs.encryptionType = AesCbc256_B64
 	}
 
-	switch cs.encryptionType {
-	case AesCbc256_B64:
-		if len(encPieces) != 2 {
-			return nil, fmt.Errorf("invalid key body len %d", len(encPieces))
-		}
-		cs.initializationVector = encPieces[0]
-		cs.cipherText = encPieces[1]
-	case AesCbc256_HmacSha256_B64:
-		if len(encPieces) != 3 {
-			return nil, fmt.Errorf("invalid key body len %d", len(encPieces))
+	hasIV, hasMac, err := cipherStringLayout(cs.encryptionType)
+	if err != nil {
+		return CipherString{}, err
+	}
+	pieces := strings.Split(body, "|")
+	n := 1
+	if hasIV {
+		n++
+	}
+	if hasMac {
+		n++
+	}
+	if len(pieces) != n {
+		return CipherString{}, fmt.Errorf("invalid cipher string body len %d", len(pieces))
+	}
+
+	b := make([][]byte, len(pieces))
+	for i, p := range pieces {
+		if b[i], err = base64.StdEncoding.DecodeString(p); err != nil {
+			return CipherString{}, err
 		}
-		cs.initializationVector = encPieces[0]
-		cs.cipherText = encPieces[1]
-		cs.mac = encPieces[2]
-	default:
-		return nil, errors.New("unknown algorithm")
 	}
-	return &cs, nil
+	if hasIV {
+		cs.iv, b = b[0], b[1:]
+	}
+	cs.ct = b[0]
+	if hasMac {
+		cs.mac = b[1]
+	}
+	if err := cs.validate(); err != nil {
+		return CipherString{}, err
+	}
+	return cs, nil
+}
+
+// NewCipherStringRaw creates a cipher string from its pieces. iv and mac have
+// to be nil for encryption types that don't use them.
+func NewCipherStringRaw(encryptionType int, ct []byte, iv []byte, mac []byte) (CipherString, error) {
+	cs := CipherString{encryptionType: encryptionType, ct: ct, iv: iv, mac: mac}
+	if err := cs.validate(); err != nil {
+		return CipherString{}, err
+	}
+	return cs, nil
+}
+
+// cipherStringLayout returns which pieces besides the cipher text an
+// encryption type has.
+func cipherStringLayout(encryptionType int) (hasIV bool, hasMac bool, err error) {
+	switch encryptionType {
+	case AesCbc256_B64:
+		return true, false, nil
+	case AesCbc128_HmacSha256_B64, AesCbc256_HmacSha256_B64:
+		return true, true, nil
+	case Rsa2048_OaepSha256_B64, Rsa2048_OaepSha1_B64:
+		return false, false, nil
+	case Rsa2048_OaepSha256_HmacSha256_B64, Rsa2048_OaepSha1_HmacSha256_B64:
+		return false, true, nil
+	}
+	return false, false, fmt.Errorf("unknown encryption type %d", encryptionType)
+}
+
+func (cs CipherString) validate() error {
+	hasIV, hasMac, err := cipherStringLayout(cs.encryptionType)
+	if err != nil {
+		return err
+	}
+	if len(cs.ct) == 0 {
+		return errors.New("empty cipher text")
+	}
+	if hasIV != (len(cs.iv) > 0) || hasMac != (len(cs.mac) > 0) {
+		return fmt.Errorf("invalid cipher string for encryption type %d", cs.encryptionType)
+	}
+	return nil
+}
+
+// IsZero reports whether cs is the empty cipher string.
+func (cs CipherString) IsZero() bool {
+	return len(cs.ct) == 0 && cs.err == nil
+}
+
+// EncryptionType returns one of the AesCbc* and Rsa2048_* constants, or -1
+// if the cipher string couldn't be parsed.
+func (cs CipherString) EncryptionType() int {
+	return cs.encryptionType
+}
+
+// Err returns the error of an unmarshalled cipher string that couldn't be
+// parsed.
+func (cs CipherString) Err() error {
+	return cs.err
+}
+
+// String returns the cipher string with type header, also for cipher strings
+// parsed from the legacy format.
+func (cs CipherString) String() string {
+	if cs.err != nil {
+		return cs.raw
+	}
+	if cs.IsZero() {
+		return ""
+	}
+	pieces := make([]string, 0, 3)
+	if cs.iv != nil {
+		pieces = append(pieces, base64.StdEncoding.EncodeToString(cs.iv))
+	}
+	pieces = append(pieces, base64.StdEncoding.EncodeToString(cs.ct))
+	if cs.mac != nil {
+		pieces = append(pieces, base64.StdEncoding.EncodeToString(cs.mac))
+	}
+	return strconv.Itoa(cs.encryptionType) + "." + strings.Join(pieces, "|")
+}
+
+func (cs CipherString) MarshalText() ([]byte, error) {
+	return []byte(cs.String()), nil
+}
+
+func (cs *CipherString) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*cs = CipherString{}
+		return nil
+	}
+	v, err := NewCipherString(string(text))
+	if err != nil {
+		v = CipherString{encryptionType: -1, raw: string(text), err: fmt.Errorf("invalid cipher string: %w", err)}
+	}
+	*cs = v
+	return nil
 }
 
-func NewCipherStringRaw(encryptionType int, ct string, iv string, mac string) (*CipherString, error) {
-	cs := CipherString{encryptionType: encryptionType, cipherText: ct, initializationVector: iv, mac: mac}
-	return &cs, nil
+func (cs CipherString) MarshalJSON() ([]byte, error) {
+	if cs.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(cs.String())
 }
 
-func (cs *CipherString) ToString() string {
-	s := cs.initializationVector + "|" + cs.cipherText
-	if cs.mac != "" {
-		s = s + "|" + cs.mac
+func (cs *CipherString) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*cs = CipherString{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
 	}
-	return fmt.Sprintf("%d.%s", cs.encryptionType, s)
+	return cs.UnmarshalText([]byte(s))
 }
 
-func (cs *CipherString) DecryptKey(key CryptoKey, encryptionType int) (CryptoKey, error) {
+func (cs CipherString) DecryptKey(key CryptoKey, encryptionType int) (CryptoKey, error) {
 	kb, err := cs.Decrypt(key)
 	if err != nil {
 		return CryptoKey{}, err
@@ -119,15 +297,54 @@ func (cs *CipherString) DecryptKey(key CryptoKey, encryptionType int) (CryptoKey
 	return k, err
 }
 
-func (cs *CipherString) Decrypt(key CryptoKey) ([]byte, error) {
-	iv, err := base64.StdEncoding.DecodeString(cs.initializationVector)
-	if err != nil {
-		return nil, err
+// DecryptUserKey decrypts the protected user key with the master key. Keys
+// encrypted with AesCbc256_HmacSha256_B64 require the stretched master key,
+// legacy AesCbc256_B64 keys are decrypted with the master key as is.
+func (cs CipherString) DecryptUserKey(masterKey CryptoKey) (CryptoKey, error) {
+	if cs.err != nil {
+		return CryptoKey{}, cs.err
+	}
+	key := masterKey
+	switch cs.encryptionType {
+	case AesCbc256_B64:
+	case AesCbc256_HmacSha256_B64:
+		sk, err := StretchKey(masterKey)
+		if err != nil {
+			return CryptoKey{}, err
+		}
+		defer zero(sk.EncKey)
+		defer zero(sk.MacKey)
+		key = sk
+	default:
+		return CryptoKey{}, fmt.Errorf("Invalid encryption type for user key: %d", cs.encryptionType)
 	}
 
-	ct, err := base64.StdEncoding.DecodeString(cs.cipherText)
+	kb, err := cs.Decrypt(key)
 	if err != nil {
-		return nil, err
+		return CryptoKey{}, err
+	}
+	if len(kb) == 32 {
+		return NewCryptoKey(kb, AesCbc256_B64)
+	}
+	return NewCryptoKey(kb, AesCbc256_HmacSha256_B64)
+}
+
+// Decrypt decrypts an AES encrypted cipher string. The MAC is required if
+// and only if the key has a MAC key.
+func (cs CipherString) Decrypt(key CryptoKey) ([]byte, error) {
+	if cs.err != nil {
+		return nil, cs.err
+	}
+	switch cs.encryptionType {
+	case AesCbc256_B64, AesCbc128_HmacSha256_B64, AesCbc256_HmacSha256_B64:
+	default:
+		return nil, fmt.Errorf("Invalid encryption type for symmetric key: %d", cs.encryptionType)
+	}
+	if len(cs.iv) != aes.BlockSize || len(cs.ct) == 0 || len(cs.ct)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("Invalid cipher string for encryption type %d", cs.encryptionType)
+	}
+	if (len(key.MacKey) > 0) != (len(cs.mac) > 0) {
+		return nil, fmt.Errorf("Encryption type %d doesn't match key type %d", cs.encryptionType, key.EncryptionType)
 	}
 
 	block, err := aes.NewCipher(key.EncKey)
@@ -135,73 +352,182 @@ func (cs *CipherString) Decrypt(key CryptoKey) ([]byte, error) {
 		return nil, err
 	}
 
-	mode := cipher.NewCBCDecrypter(block, iv)
-
-	if cs.mac != "" {
+	if len(cs.mac) > 0 {
 		mac := hmac.New(sha256.New, key.MacKey)
-		mac.Write(iv)
-		mac.Write(ct)
-		ms := mac.Sum(nil)
-		if base64.StdEncoding.EncodeToString(ms) != cs.mac {
-			return ct, fmt.Errorf("MAC doesn't match %s %s", cs.mac, base64.StdEncoding.EncodeToString(ms))
+		mac.Write(cs.iv)
+		mac.Write(cs.ct)
+		if !hmac.Equal(mac.Sum(nil), cs.mac) {
+			return nil, errors.New("MAC doesn't match")
 		}
 	}
 
-	mode.CryptBlocks(ct, ct)
+	pt := make([]byte, len(cs.ct))
+	cipher.NewCBCDecrypter(block, cs.iv).CryptBlocks(pt, cs.ct)
+
+	pt, err = padding.NewPkcs7Padding(16).Unpad(pt) //TODO, configurable size
+	return pt, err
+}
+
+// DecryptWithPrivateKey decrypts an RSA encrypted cipher string, such as an
+// organization key. The MAC of the deprecated HMAC variants isn't checked, as
+// the official clients never had a key for it.
+func (cs CipherString) DecryptWithPrivateKey(key *rsa.PrivateKey) ([]byte, error) {
+	if cs.err != nil {
+		return nil, cs.err
+	}
+	var h hash.Hash
+	switch cs.encryptionType {
+	case Rsa2048_OaepSha256_B64, Rsa2048_OaepSha256_HmacSha256_B64:
+		h = sha256.New()
+	case Rsa2048_OaepSha1_B64, Rsa2048_OaepSha1_HmacSha256_B64:
+		h = sha1.New()
+	default:
+		return nil, fmt.Errorf("Invalid encryption type for private key: %d", cs.encryptionType)
+	}
+
+	return rsa.DecryptOAEP(h, nil, key, cs.ct, nil)
+}
 
-	ct, err = padding.NewPkcs7Padding(16).Unpad(ct) //TODO, configurable size
-	return ct, err
+// EncryptWithPublicKey encrypts pt with RSA-OAEP and SHA-1, as the official
+// clients do when sharing keys.
+func EncryptWithPublicKey(pt []byte, key *rsa.PublicKey) (CipherString, error) {
+	ct, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, key, pt, nil)
+	if err != nil {
+		return CipherString{}, err
+	}
+	return CipherString{encryptionType: Rsa2048_OaepSha1_B64, ct: ct}, nil
 }
 
-func Encrypt(pt []byte, key CryptoKey) (*CipherString, error) {
+// Encrypt encrypts pt with AES-CBC and, if the key has a MAC key, adds an
+// HMAC-SHA256 over the IV and cipher text.
+func Encrypt(pt []byte, key CryptoKey) (CipherString, error) {
+	switch key.EncryptionType {
+	case AesCbc256_B64, AesCbc128_HmacSha256_B64, AesCbc256_HmacSha256_B64:
+	default:
+		return CipherString{}, fmt.Errorf("Invalid encryption type for symmetric key: %d", key.EncryptionType)
+	}
 	block, err := aes.NewCipher(key.EncKey)
 	if err != nil {
-		panic(err)
+		return CipherString{}, err
 	}
 
 	// The IV needs to be unique, but not secure.
 	iv := make([]byte, aes.BlockSize)
 
 	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		panic(err)
+		return CipherString{}, err
+	}
+	pt, err = padding.NewPkcs7Padding(16).Pad(pt) //TODO, configurable size
+	if err != nil {
+		return CipherString{}, err
 	}
-	pt, _ = padding.NewPkcs7Padding(16).Pad(pt) //TODO, configurable size
 	ct := make([]byte, len(pt))
 
 	mode := cipher.NewCBCEncrypter(block, iv)
 	mode.CryptBlocks(ct, pt)
 
-	cs := CipherString{encryptionType: key.EncryptionType, cipherText: base64.StdEncoding.EncodeToString(ct), initializationVector: base64.StdEncoding.EncodeToString(iv)}
+	cs := CipherString{encryptionType: key.EncryptionType, ct: ct, iv: iv}
 
 	if len(key.MacKey) > 0 {
 		mac := hmac.New(sha256.New, key.MacKey)
 		mac.Write(iv)
 		mac.Write(ct)
-		cs.mac = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		cs.mac = mac.Sum(nil)
 	}
-	return &cs, nil
+	return cs, nil
 }
 
-func MakeEncKey(key []byte) (*CipherString, error) {
+func MakeEncKey(key []byte) (CipherString, error) {
 	b := make([]byte, 512/8)
 	if _, err := io.ReadFull(rand.Reader, b); err != nil {
-		panic(err)
+		return CipherString{}, err
 	}
 	k, err := NewCryptoKey(key, AesCbc256_HmacSha256_B64)
 	if err != nil {
-		return nil, err
+		return CipherString{}, err
 	}
 	return Encrypt(b, k)
 
 }
 
-func MakeKey(password string, salt string) CryptoKey {
-	dk := pbkdf2.Key([]byte(password), []byte(salt), 5000, 256/8, sha256.New)
+// MakeKey derives the master key from the password, salted with the account's
+// email address, using the account's KDF settings as returned by Prelogin.
+func MakeKey(password string, salt string, kdf KdfConfig) (CryptoKey, error) {
+	var dk []byte
+	switch kdf.Type {
+	case KdfType_PBKDF2_SHA256:
+		if kdf.Iterations < minPBKDF2Iterations {
+			return CryptoKey{}, fmt.Errorf("invalid PBKDF2 iterations: %d", kdf.Iterations)
+		}
+		dk = pbkdf2.Key([]byte(password), []byte(salt), kdf.Iterations, 256/8, sha256.New)
+	case KdfType_Argon2id:
+		if kdf.Iterations < minArgon2Iterations {
+			return CryptoKey{}, fmt.Errorf("invalid Argon2id iterations: %d", kdf.Iterations)
+		}
+		if kdf.Memory < minArgon2Memory || kdf.Memory > maxArgon2Memory {
+			return CryptoKey{}, fmt.Errorf("invalid Argon2id memory: %d MiB", kdf.Memory)
+		}
+		if kdf.Parallelism < 1 || kdf.Parallelism > maxArgon2Parallelism {
+			return CryptoKey{}, fmt.Errorf("invalid Argon2id parallelism: %d", kdf.Parallelism)
+		}
+		s := sha256.Sum256([]byte(salt))
+		dk = argon2.IDKey([]byte(password), s[:], uint32(kdf.Iterations), uint32(kdf.Memory)*1024, uint8(kdf.Parallelism), 256/8)
+	default:
+		return CryptoKey{}, fmt.Errorf("unknown KDF type: %d", kdf.Type)
+	}
 	k := CryptoKey{EncKey: dk, EncryptionType: AesCbc256_B64}
-	return k
+	return k, nil
+}
+
+// StretchKey expands a master key into separate encryption and MAC keys
+// using HKDF-Expand with the infos "enc" and "mac".
+func StretchKey(key CryptoKey) (CryptoKey, error) {
+	if len(key.EncKey) != 32 || len(key.MacKey) != 0 {
+		return CryptoKey{}, fmt.Errorf("Invalid key size: %d", len(key.EncKey)+len(key.MacKey))
+	}
+	enc := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, key.EncKey, []byte("enc")), enc); err != nil {
+		return CryptoKey{}, err
+	}
+	mac := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, key.EncKey, []byte("mac")), mac); err != nil {
+		return CryptoKey{}, err
+	}
+	return CryptoKey{EncKey: enc, MacKey: mac, EncryptionType: AesCbc256_HmacSha256_B64}, nil
+}
+
+// MakeSendKey derives the key of a Send from its 16 bytes of key material.
+func MakeSendKey(keyMaterial []byte) (CryptoKey, error) {
+	b := make([]byte, 64)
+	defer zero(b)
+	r := hkdf.New(sha256.New, keyMaterial, []byte("bitwarden-send"), []byte("send"))
+	if _, err := io.ReadFull(r, b); err != nil {
+		return CryptoKey{}, err
+	}
+	return NewCryptoKey(append([]byte(nil), b...), AesCbc256_HmacSha256_B64)
+}
+
+// HashSendPassword hashes the access password of a Send, salted with the
+// Send's key material.
+func HashSendPassword(password string, keyMaterial []byte) string {
+	hash := pbkdf2.Key([]byte(password), keyMaterial, sendPasswordIterations, 256/8, sha256.New)
+	return base64.StdEncoding.EncodeToString(hash)
 }
 
 func HashPassword(password string, key CryptoKey) string {
 	hash := pbkdf2.Key(key.EncKey, []byte(password), 1, 256/8, sha256.New)
 	return base64.StdEncoding.EncodeToString(hash)
 }
+
+// zero overwrites key material that is no longer needed.
+func zero(b []byte) {
+	clear(b)
+}
+
+func zeroInt(n *big.Int) {
+	if n == nil {
+		return
+	}
+	clear(n.Bits())
+	n.SetInt64(0)
+}
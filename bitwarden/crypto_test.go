@@ -1,6 +1,11 @@
 package bitwarden
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -21,7 +26,10 @@ func TestCrypto(t *testing.T) {
 		t.Error(err)
 	}
 
-	dk := MakeKey(password, email)
+	dk, err := MakeKey(password, email, KdfConfig{Type: KdfType_PBKDF2_SHA256, Iterations: 5000})
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// MasterPasswordHash
 	hash := HashPassword(password, dk)
@@ -48,8 +56,8 @@ func TestCrypto(t *testing.T) {
 		t.Error(err)
 	}
 
-	if cs.ToString() != enckey {
-		t.Errorf("Expected %v got %v", cs.ToString(), enckey)
+	if cs.String() != enckey {
+		t.Errorf("Expected %v got %v", cs.String(), enckey)
 
 	}
 
@@ -72,8 +80,8 @@ func TestCrypto(t *testing.T) {
 		t.Error(err)
 	}
 
-	if ds.ToString() != encdata {
-		t.Errorf("Expected %v got %v", ds.ToString(), encdata)
+	if ds.String() != encdata {
+		t.Errorf("Expected %v got %v", ds.String(), encdata)
 
 	}
 
@@ -87,3 +95,224 @@ func TestCrypto(t *testing.T) {
 	}
 
 }
+
+func TestCipherStringFormats(t *testing.T) {
+	dk, err := MakeKey("password", "test@example.com", legacyKdfConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var enckey = "0.yMeH5ypzRLcyJX69HAt6mQ==|H0mdMpoX1aguKIaCXOreL93JyCpo9ORiX8ZbK+taLXlGZfCb5TOs0eriKa7u1ocBp9gDHwYm5EUyobnbVfZ3uiP2suYWAXKmC4IO67b7ozc="
+
+	// Legacy cipher strings have no header.
+	cs, err := NewCipherString(enckey[2:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs.EncryptionType() != AesCbc256_B64 || cs.String() != enckey {
+		t.Errorf("Unexpected cipher string %v", cs)
+	}
+	if _, err := cs.Decrypt(dk); err != nil {
+		t.Error(err)
+	}
+
+	key, err := NewCryptoKey(bytes.Repeat([]byte{1}, 32), AesCbc128_HmacSha256_B64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := Encrypt([]byte("test"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := ct.String()
+	if !strings.HasPrefix(s, "1.") {
+		t.Fatalf("Unexpected cipher string %v", s)
+	}
+	for _, v := range []string{s, s[2:]} {
+		cs, err := NewCipherString(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pt, err := cs.Decrypt(key); err != nil || string(pt) != "test" {
+			t.Errorf("Unexpected plain text %q: %v", pt, err)
+		}
+	}
+
+	// The MAC can't be stripped to downgrade a cipher string.
+	mk, err := StretchKey(dk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err = Encrypt([]byte("test"), mk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stripped, err := NewCipherStringRaw(AesCbc256_B64, ct.ct, ct.iv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stripped.Decrypt(mk); err == nil {
+		t.Error("Expected error for cipher string without MAC")
+	}
+
+	for _, s := range []string{"", "x.a2V5", "2.a2V5|a2V5", "7.a2V5", "2.a2V5|!!|a2V5", "0.|a2V5", "a2V5", "1.a2V5|a2V5|a2V5|a2V5"} {
+		if _, err := NewCipherString(s); err == nil {
+			t.Errorf("Expected error for %q", s)
+		}
+	}
+}
+
+func TestCipherStringJSON(t *testing.T) {
+	type item struct {
+		A CipherString
+		B *CipherString
+		C CipherString
+	}
+	var v item
+	if err := json.Unmarshal([]byte(`{"A":"2.aXY=|Y3Q=|bWFj","B":"4.a2V5","C":null}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A.String() != "2.aXY=|Y3Q=|bWFj" || v.B == nil || v.B.EncryptionType() != Rsa2048_OaepSha1_B64 || !v.C.IsZero() {
+		t.Errorf("Unexpected item %+v", v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"A":"2.aXY=|Y3Q=|bWFj","B":"4.a2V5","C":null}` {
+		t.Errorf("Unexpected JSON %s", b)
+	}
+
+	if err := json.Unmarshal([]byte(`{"A":""}`), &v); err != nil || !v.A.IsZero() {
+		t.Errorf("Expected empty cipher string: %v %+v", err, v)
+	}
+
+	// Cipher strings that can't be parsed are kept as is and fail to decrypt.
+	for _, s := range []string{"2.invalid", "7.AAAA", "x"} {
+		var u item
+		in := `{"A":"` + s + `","B":null,"C":null}`
+		if err := json.Unmarshal([]byte(in), &u); err != nil {
+			t.Fatal(err)
+		}
+		if u.A.IsZero() || u.A.Err() == nil || u.A.String() != s || u.A.EncryptionType() != -1 {
+			t.Errorf("%s: unexpected cipher string %+v", s, u.A)
+		}
+		if _, err := u.A.Decrypt(CryptoKey{EncKey: make([]byte, 32), EncryptionType: AesCbc256_B64}); err == nil || err != u.A.Err() {
+			t.Errorf("%s: expected the parse error from Decrypt, got %v", s, err)
+		}
+		if b, err := json.Marshal(u); err != nil || string(b) != in {
+			t.Errorf("%s: round trip changed JSON %s: %v", s, b, err)
+		}
+	}
+
+	text, err := v.B.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cs CipherString
+	if err := cs.UnmarshalText(text); err != nil || !reflect.DeepEqual(cs, *v.B) {
+		t.Errorf("Text round trip changed cipher string: %v %v", err, cs)
+	}
+}
+
+func TestMakeKeyArgon2id(t *testing.T) {
+	kdf := KdfConfig{Type: KdfType_Argon2id, Iterations: 3, Memory: 64, Parallelism: 4}
+	dk, err := MakeKey("password", "test@example.com", kdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "9uSFSIzMJ3RKGhoz39QkbN433lVxzmZgi7GyURCEl9A="
+	if s := base64.StdEncoding.EncodeToString(dk.EncKey); s != expected {
+		t.Errorf("Expected %v got %v", expected, s)
+	}
+}
+
+func TestMakeKeyInvalidKdf(t *testing.T) {
+	for _, kdf := range []KdfConfig{
+		{},
+		{Type: KdfType_PBKDF2_SHA256, Iterations: 1},
+		{Type: KdfType_Argon2id, Iterations: 3, Memory: 64},
+		{Type: KdfType_Argon2id, Iterations: 3, Memory: 4096, Parallelism: 4},
+		{Type: KdfType_Argon2id, Iterations: 0, Memory: 64, Parallelism: 4},
+		{Type: KdfType_Argon2id, Iterations: 1, Memory: 64, Parallelism: 4},
+		{Type: 2, Iterations: 600000},
+	} {
+		if _, err := MakeKey("password", "test@example.com", kdf); err == nil {
+			t.Errorf("Expected error for %+v", kdf)
+		}
+	}
+
+	// The lowest settings the server accepts.
+	for _, kdf := range []KdfConfig{
+		{Type: KdfType_PBKDF2_SHA256, Iterations: 5000},
+		{Type: KdfType_Argon2id, Iterations: 2, Memory: 15, Parallelism: 1},
+	} {
+		if _, err := MakeKey("password", "test@example.com", kdf); err != nil {
+			t.Errorf("%+v: %v", kdf, err)
+		}
+	}
+}
+
+func TestStretchKey(t *testing.T) {
+	mk, err := MakeKey("password", "test@example.com", KdfConfig{Type: KdfType_PBKDF2_SHA256, Iterations: 5000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := base64.StdEncoding.EncodeToString(mk.EncKey); s != "jUz2UJsI3O39p+TWPQ03kVCbMDHQkQ42ujq4pew1/0M=" {
+		t.Fatalf("Unexpected master key %v", s)
+	}
+
+	sk, err := StretchKey(mk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sk.EncryptionType != AesCbc256_HmacSha256_B64 {
+		t.Errorf("StretchKey: invalid EncryptionType")
+	}
+	if s := base64.StdEncoding.EncodeToString(sk.EncKey); s != "bo/meDi7VMcxq6FyW2oV7CzTDh6CGi7p8yhBhydOc5E=" {
+		t.Errorf("Unexpected enc key %v", s)
+	}
+	if s := base64.StdEncoding.EncodeToString(sk.MacKey); s != "FPbhhH3lqPCy1ffvjpuQKTJ3p1vpbvmcOU50uSZqQJw=" {
+		t.Errorf("Unexpected mac key %v", s)
+	}
+
+	if _, err := StretchKey(sk); err == nil {
+		t.Error("Expected error for 64 byte key")
+	}
+}
+
+func TestDecryptUserKey(t *testing.T) {
+	mk, err := MakeKey("password", "test@example.com", KdfConfig{Type: KdfType_PBKDF2_SHA256, Iterations: 5000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		protectedKey string
+		userKey      string
+	}{
+		// Legacy key, encrypted with the master key
+		{"0.yMeH5ypzRLcyJX69HAt6mQ==|H0mdMpoX1aguKIaCXOreL93JyCpo9ORiX8ZbK+taLXlGZfCb5TOs0eriKa7u1ocBp9gDHwYm5EUyobnbVfZ3uiP2suYWAXKmC4IO67b7ozc=", ""},
+		// Current key, encrypted with the stretched master key
+		{"2.ZGVmZ2hpamtsbW5vcHFycw==|ZZ4OamUfiqTFftav8ThXhMnJhJYdnzKKR9MuO8paTlCtxqvexhBzCrpxU8zJCudKIwEL6F5nn12AIgEJT+1iB2M4oVvFy40jpiZGb1P7mPg=|9ILmxcS/Nqk/VnMduh1GTEQCoo3g7Pkvzw3vYy3iSBA=", "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8gISIjJCUmJygpKissLS4vMDEyMzQ1Njc4OTo7PD0+Pw=="},
+	}
+
+	for _, test := range tests {
+		cs, err := NewCipherString(test.protectedKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		uk, err := cs.DecryptUserKey(mk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uk.EncryptionType != AesCbc256_HmacSha256_B64 || len(uk.EncKey) != 32 || len(uk.MacKey) != 32 {
+			t.Errorf("Unexpected user key %+v", uk)
+		}
+		if test.userKey != "" {
+			if s := base64.StdEncoding.EncodeToString(append(uk.EncKey, uk.MacKey...)); s != test.userKey {
+				t.Errorf("Expected %v got %v", test.userKey, s)
+			}
+		}
+	}
+}
@@ -0,0 +1,579 @@
+package bitwarden
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FieldError is the error of a single field that failed to encrypt or
+// decrypt. The Encrypt and Decrypt methods of the cipher data types return
+// the errors of all fields joined with errors.Join.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// CipherError is the error of a single cipher that failed to decrypt, see
+// Session.Ciphers.
+type CipherError struct {
+	Id  string
+	Err error
+}
+
+func (e *CipherError) Error() string {
+	return "cipher " + e.Id + ": " + e.Err.Error()
+}
+
+func (e *CipherError) Unwrap() error {
+	return e.Err
+}
+
+// FolderError is the error of a single folder that failed to decrypt, see
+// Session.Folders.
+type FolderError struct {
+	Id  string
+	Err error
+}
+
+func (e *FolderError) Error() string {
+	return "folder " + e.Id + ": " + e.Err.Error()
+}
+
+func (e *FolderError) Unwrap() error {
+	return e.Err
+}
+
+// cryptor encrypts or decrypts the fields of an item with one key and
+// collects the errors of all fields. A field that fails is left empty.
+type cryptor struct {
+	key  CryptoKey
+	errs []error
+}
+
+func (c *cryptor) fail(field string, err error) {
+	c.errs = append(c.errs, &FieldError{Field: field, Err: err})
+}
+
+func (c *cryptor) err() error {
+	return errors.Join(c.errs...)
+}
+
+// encrypt leaves empty values empty.
+func (c *cryptor) encrypt(field string, s string) CipherString {
+	if s == "" {
+		return CipherString{}
+	}
+	v, err := Encrypt([]byte(s), c.key)
+	if err != nil {
+		c.fail(field, err)
+		return CipherString{}
+	}
+	return v
+}
+
+// encryptPtr clears empty values.
+func (c *cryptor) encryptPtr(field string, s *string) *CipherString {
+	if s == nil {
+		return nil
+	}
+	v := c.encrypt(field, *s)
+	if v.IsZero() {
+		return nil
+	}
+	return &v
+}
+
+func (c *cryptor) decrypt(field string, s CipherString) string {
+	if s.IsZero() {
+		return ""
+	}
+	v, err := s.Decrypt(c.key)
+	if err != nil {
+		c.fail(field, err)
+		return ""
+	}
+	return string(v)
+}
+
+func (c *cryptor) decryptPtr(field string, s *CipherString) *string {
+	if s == nil {
+		return nil
+	}
+	n := len(c.errs)
+	v := c.decrypt(field, *s)
+	if len(c.errs) > n {
+		return nil
+	}
+	return &v
+}
+
+type EncryptedCipherData struct {
+	Name   *CipherString         `json:"Name,omitempty"`
+	Notes  *CipherString         `json:"Notes,omitempty"`
+	Fields *[]EncryptedFieldData `json:"Fields,omitempty"`
+}
+
+type EncryptedFieldData struct {
+	Type     int // FieldType_*
+	Name     CipherString
+	Value    CipherString
+	LinkedId *int `json:"LinkedId,omitempty"`
+}
+
+type EncryptedPasswordHistoryData struct {
+	Password     CipherString
+	LastUsedDate *Time
+}
+
+type EncryptedLoginData struct {
+	EncryptedCipherData
+	URI                  *CipherString                   `json:"Uri"`
+	Uris                 *[]EncryptedLoginUriData        `json:"Uris,omitempty"`
+	Username             *CipherString                   `json:"Username"`
+	Password             *CipherString                   `json:"Password"`
+	PasswordRevisionDate *Time                           `json:"PasswordRevisionDate,omitempty"`
+	ToTp                 *CipherString                   `json:"Totp"`
+	AutofillOnPageLoad   *bool                           `json:"AutofillOnPageLoad,omitempty"`
+	Fido2Credentials     *[]EncryptedFido2CredentialData `json:"Fido2Credentials,omitempty"`
+}
+
+type EncryptedLoginUriData struct {
+	Uri         *CipherString
+	UriChecksum *CipherString `json:"UriChecksum,omitempty"`
+	Match       *int          // UriMatchType_*
+}
+
+type EncryptedFido2CredentialData struct {
+	CredentialId    CipherString
+	KeyType         CipherString
+	KeyAlgorithm    CipherString
+	KeyCurve        CipherString
+	KeyValue        CipherString
+	RpId            CipherString
+	RpName          CipherString
+	UserHandle      CipherString
+	UserName        CipherString
+	UserDisplayName CipherString
+	Counter         CipherString
+	Discoverable    CipherString
+	CreationDate    Time
+}
+
+type EncryptedCardData struct {
+	EncryptedCipherData
+	CardholderName *CipherString
+	Brand          *CipherString
+	Number         *CipherString
+	ExpMonth       *CipherString
+	ExpYear        *CipherString
+	Code           *CipherString
+}
+
+type EncryptedIdentityData struct {
+	EncryptedCipherData
+	Title          *CipherString
+	FirstName      *CipherString
+	MiddleName     *CipherString
+	LastName       *CipherString
+	Address1       *CipherString
+	Address2       *CipherString
+	Address3       *CipherString
+	City           *CipherString
+	State          *CipherString
+	PostalCode     *CipherString
+	Country        *CipherString
+	Company        *CipherString
+	Email          *CipherString
+	Phone          *CipherString
+	SSN            *CipherString
+	Username       *CipherString
+	PassportNumber *CipherString
+	LicenseNumber  *CipherString
+}
+
+type EncryptedSecureNoteData struct {
+	EncryptedCipherData
+	Type SecureNoteType
+}
+
+type EncryptedSshKeyData struct {
+	EncryptedCipherData
+	PrivateKey     *CipherString
+	PublicKey      *CipherString
+	KeyFingerprint *CipherString
+}
+
+func (d *CipherData) encrypt(c *cryptor) EncryptedCipherData {
+	e := EncryptedCipherData{
+		Name:  c.encryptPtr("Name", d.Name),
+		Notes: c.encryptPtr("Notes", d.Notes),
+	}
+	if d.Fields != nil {
+		fields := make([]EncryptedFieldData, len(*d.Fields))
+		for i, f := range *d.Fields {
+			fields[i] = EncryptedFieldData{
+				Type:     f.Type,
+				Name:     c.encrypt(fmt.Sprintf("Fields[%d].Name", i), f.Name),
+				Value:    c.encrypt(fmt.Sprintf("Fields[%d].Value", i), f.Value),
+				LinkedId: f.LinkedId,
+			}
+		}
+		e.Fields = &fields
+	}
+	return e
+}
+
+func (e *EncryptedCipherData) decrypt(c *cryptor) CipherData {
+	d := CipherData{
+		Name:  c.decryptPtr("Name", e.Name),
+		Notes: c.decryptPtr("Notes", e.Notes),
+	}
+	if e.Fields != nil {
+		fields := make([]FieldData, len(*e.Fields))
+		for i, f := range *e.Fields {
+			fields[i] = FieldData{
+				Type:     f.Type,
+				Name:     c.decrypt(fmt.Sprintf("Fields[%d].Name", i), f.Name),
+				Value:    c.decrypt(fmt.Sprintf("Fields[%d].Value", i), f.Value),
+				LinkedId: f.LinkedId,
+			}
+		}
+		d.Fields = &fields
+	}
+	return d
+}
+
+// Encrypt encrypts a login. URIs get a checksum, see LoginUriData.
+func (d *LoginData) Encrypt(key CryptoKey) (*EncryptedLoginData, error) {
+	c := cryptor{key: key}
+	e := d.encrypt(&c)
+	return e, c.err()
+}
+
+func (d *LoginData) encrypt(c *cryptor) *EncryptedLoginData {
+	if d == nil {
+		return nil
+	}
+	e := &EncryptedLoginData{
+		EncryptedCipherData:  d.CipherData.encrypt(c),
+		URI:                  c.encryptPtr("Uri", d.URI),
+		Username:             c.encryptPtr("Username", d.Username),
+		Password:             c.encryptPtr("Password", d.Password),
+		PasswordRevisionDate: d.PasswordRevisionDate,
+		ToTp:                 c.encryptPtr("Totp", d.ToTp),
+		AutofillOnPageLoad:   d.AutofillOnPageLoad,
+	}
+	if d.Uris != nil {
+		uris := make([]EncryptedLoginUriData, len(*d.Uris))
+		for i, u := range *d.Uris {
+			sum := u.UriChecksum
+			if u.Uri != nil && *u.Uri != "" {
+				sum = uriChecksum(*u.Uri)
+			}
+			uris[i] = EncryptedLoginUriData{
+				Uri:         c.encryptPtr(fmt.Sprintf("Uris[%d].Uri", i), u.Uri),
+				UriChecksum: c.encryptPtr(fmt.Sprintf("Uris[%d].UriChecksum", i), sum),
+				Match:       u.Match,
+			}
+		}
+		e.Uris = &uris
+	}
+	if d.Fido2Credentials != nil {
+		creds := make([]EncryptedFido2CredentialData, len(*d.Fido2Credentials))
+		for i, f := range *d.Fido2Credentials {
+			field := func(name string) string { return fmt.Sprintf("Fido2Credentials[%d].%s", i, name) }
+			creds[i] = EncryptedFido2CredentialData{
+				CredentialId:    c.encrypt(field("CredentialId"), f.CredentialId),
+				KeyType:         c.encrypt(field("KeyType"), f.KeyType),
+				KeyAlgorithm:    c.encrypt(field("KeyAlgorithm"), f.KeyAlgorithm),
+				KeyCurve:        c.encrypt(field("KeyCurve"), f.KeyCurve),
+				KeyValue:        c.encrypt(field("KeyValue"), f.KeyValue),
+				RpId:            c.encrypt(field("RpId"), f.RpId),
+				RpName:          c.encrypt(field("RpName"), f.RpName),
+				UserHandle:      c.encrypt(field("UserHandle"), f.UserHandle),
+				UserName:        c.encrypt(field("UserName"), f.UserName),
+				UserDisplayName: c.encrypt(field("UserDisplayName"), f.UserDisplayName),
+				Counter:         c.encrypt(field("Counter"), f.Counter),
+				Discoverable:    c.encrypt(field("Discoverable"), f.Discoverable),
+				CreationDate:    f.CreationDate,
+			}
+		}
+		e.Fido2Credentials = &creds
+	}
+	return e
+}
+
+func (e *EncryptedLoginData) Decrypt(key CryptoKey) (*LoginData, error) {
+	c := cryptor{key: key}
+	d := e.decrypt(&c)
+	return d, c.err()
+}
+
+func (e *EncryptedLoginData) decrypt(c *cryptor) *LoginData {
+	if e == nil {
+		return nil
+	}
+	d := &LoginData{
+		CipherData:           e.EncryptedCipherData.decrypt(c),
+		URI:                  c.decryptPtr("Uri", e.URI),
+		Username:             c.decryptPtr("Username", e.Username),
+		Password:             c.decryptPtr("Password", e.Password),
+		PasswordRevisionDate: e.PasswordRevisionDate,
+		ToTp:                 c.decryptPtr("Totp", e.ToTp),
+		AutofillOnPageLoad:   e.AutofillOnPageLoad,
+	}
+	if e.Uris != nil {
+		uris := make([]LoginUriData, 0, len(*e.Uris))
+		for i, u := range *e.Uris {
+			uri := LoginUriData{
+				Uri:         c.decryptPtr(fmt.Sprintf("Uris[%d].Uri", i), u.Uri),
+				UriChecksum: c.decryptPtr(fmt.Sprintf("Uris[%d].UriChecksum", i), u.UriChecksum),
+				Match:       u.Match,
+			}
+			// Like the official clients, drop URIs that don't match their
+			// checksum instead of offering the credentials to another site.
+			if uri.Uri != nil && uri.UriChecksum != nil && *uri.UriChecksum != *uriChecksum(*uri.Uri) {
+				c.fail(fmt.Sprintf("Uris[%d].UriChecksum", i), ErrUriChecksum)
+				continue
+			}
+			uris = append(uris, uri)
+		}
+		d.Uris = &uris
+	}
+	if e.Fido2Credentials != nil {
+		creds := make([]Fido2CredentialData, len(*e.Fido2Credentials))
+		for i, f := range *e.Fido2Credentials {
+			field := func(name string) string { return fmt.Sprintf("Fido2Credentials[%d].%s", i, name) }
+			creds[i] = Fido2CredentialData{
+				CredentialId:    c.decrypt(field("CredentialId"), f.CredentialId),
+				KeyType:         c.decrypt(field("KeyType"), f.KeyType),
+				KeyAlgorithm:    c.decrypt(field("KeyAlgorithm"), f.KeyAlgorithm),
+				KeyCurve:        c.decrypt(field("KeyCurve"), f.KeyCurve),
+				KeyValue:        c.decrypt(field("KeyValue"), f.KeyValue),
+				RpId:            c.decrypt(field("RpId"), f.RpId),
+				RpName:          c.decrypt(field("RpName"), f.RpName),
+				UserHandle:      c.decrypt(field("UserHandle"), f.UserHandle),
+				UserName:        c.decrypt(field("UserName"), f.UserName),
+				UserDisplayName: c.decrypt(field("UserDisplayName"), f.UserDisplayName),
+				Counter:         c.decrypt(field("Counter"), f.Counter),
+				Discoverable:    c.decrypt(field("Discoverable"), f.Discoverable),
+				CreationDate:    f.CreationDate,
+			}
+		}
+		d.Fido2Credentials = &creds
+	}
+	return d
+}
+
+func (d *CardData) Encrypt(key CryptoKey) (*EncryptedCardData, error) {
+	c := cryptor{key: key}
+	e := d.encrypt(&c)
+	return e, c.err()
+}
+
+func (d *CardData) encrypt(c *cryptor) *EncryptedCardData {
+	if d == nil {
+		return nil
+	}
+	return &EncryptedCardData{
+		EncryptedCipherData: d.CipherData.encrypt(c),
+		CardholderName:      c.encryptPtr("CardholderName", d.CardholderName),
+		Brand:               c.encryptPtr("Brand", d.Brand),
+		Number:              c.encryptPtr("Number", d.Number),
+		ExpMonth:            c.encryptPtr("ExpMonth", d.ExpMonth),
+		ExpYear:             c.encryptPtr("ExpYear", d.ExpYear),
+		Code:                c.encryptPtr("Code", d.Code),
+	}
+}
+
+func (e *EncryptedCardData) Decrypt(key CryptoKey) (*CardData, error) {
+	c := cryptor{key: key}
+	d := e.decrypt(&c)
+	return d, c.err()
+}
+
+func (e *EncryptedCardData) decrypt(c *cryptor) *CardData {
+	if e == nil {
+		return nil
+	}
+	return &CardData{
+		CipherData:     e.EncryptedCipherData.decrypt(c),
+		CardholderName: c.decryptPtr("CardholderName", e.CardholderName),
+		Brand:          c.decryptPtr("Brand", e.Brand),
+		Number:         c.decryptPtr("Number", e.Number),
+		ExpMonth:       c.decryptPtr("ExpMonth", e.ExpMonth),
+		ExpYear:        c.decryptPtr("ExpYear", e.ExpYear),
+		Code:           c.decryptPtr("Code", e.Code),
+	}
+}
+
+func (d *IdentityData) Encrypt(key CryptoKey) (*EncryptedIdentityData, error) {
+	c := cryptor{key: key}
+	e := d.encrypt(&c)
+	return e, c.err()
+}
+
+func (d *IdentityData) encrypt(c *cryptor) *EncryptedIdentityData {
+	if d == nil {
+		return nil
+	}
+	return &EncryptedIdentityData{
+		EncryptedCipherData: d.CipherData.encrypt(c),
+		Title:               c.encryptPtr("Title", d.Title),
+		FirstName:           c.encryptPtr("FirstName", d.FirstName),
+		MiddleName:          c.encryptPtr("MiddleName", d.MiddleName),
+		LastName:            c.encryptPtr("LastName", d.LastName),
+		Address1:            c.encryptPtr("Address1", d.Address1),
+		Address2:            c.encryptPtr("Address2", d.Address2),
+		Address3:            c.encryptPtr("Address3", d.Address3),
+		City:                c.encryptPtr("City", d.City),
+		State:               c.encryptPtr("State", d.State),
+		PostalCode:          c.encryptPtr("PostalCode", d.PostalCode),
+		Country:             c.encryptPtr("Country", d.Country),
+		Company:             c.encryptPtr("Company", d.Company),
+		Email:               c.encryptPtr("Email", d.Email),
+		Phone:               c.encryptPtr("Phone", d.Phone),
+		SSN:                 c.encryptPtr("SSN", d.SSN),
+		Username:            c.encryptPtr("Username", d.Username),
+		PassportNumber:      c.encryptPtr("PassportNumber", d.PassportNumber),
+		LicenseNumber:       c.encryptPtr("LicenseNumber", d.LicenseNumber),
+	}
+}
+
+func (e *EncryptedIdentityData) Decrypt(key CryptoKey) (*IdentityData, error) {
+	c := cryptor{key: key}
+	d := e.decrypt(&c)
+	return d, c.err()
+}
+
+func (e *EncryptedIdentityData) decrypt(c *cryptor) *IdentityData {
+	if e == nil {
+		return nil
+	}
+	return &IdentityData{
+		CipherData:     e.EncryptedCipherData.decrypt(c),
+		Title:          c.decryptPtr("Title", e.Title),
+		FirstName:      c.decryptPtr("FirstName", e.FirstName),
+		MiddleName:     c.decryptPtr("MiddleName", e.MiddleName),
+		LastName:       c.decryptPtr("LastName", e.LastName),
+		Address1:       c.decryptPtr("Address1", e.Address1),
+		Address2:       c.decryptPtr("Address2", e.Address2),
+		Address3:       c.decryptPtr("Address3", e.Address3),
+		City:           c.decryptPtr("City", e.City),
+		State:          c.decryptPtr("State", e.State),
+		PostalCode:     c.decryptPtr("PostalCode", e.PostalCode),
+		Country:        c.decryptPtr("Country", e.Country),
+		Company:        c.decryptPtr("Company", e.Company),
+		Email:          c.decryptPtr("Email", e.Email),
+		Phone:          c.decryptPtr("Phone", e.Phone),
+		SSN:            c.decryptPtr("SSN", e.SSN),
+		Username:       c.decryptPtr("Username", e.Username),
+		PassportNumber: c.decryptPtr("PassportNumber", e.PassportNumber),
+		LicenseNumber:  c.decryptPtr("LicenseNumber", e.LicenseNumber),
+	}
+}
+
+// Encrypt encrypts a secure note. Its type isn't encrypted.
+func (d *SecureNoteData) Encrypt(key CryptoKey) (*EncryptedSecureNoteData, error) {
+	c := cryptor{key: key}
+	e := d.encrypt(&c)
+	return e, c.err()
+}
+
+func (d *SecureNoteData) encrypt(c *cryptor) *EncryptedSecureNoteData {
+	if d == nil {
+		return nil
+	}
+	return &EncryptedSecureNoteData{
+		EncryptedCipherData: d.CipherData.encrypt(c),
+		Type:                d.Type,
+	}
+}
+
+func (e *EncryptedSecureNoteData) Decrypt(key CryptoKey) (*SecureNoteData, error) {
+	c := cryptor{key: key}
+	d := e.decrypt(&c)
+	return d, c.err()
+}
+
+func (e *EncryptedSecureNoteData) decrypt(c *cryptor) *SecureNoteData {
+	if e == nil {
+		return nil
+	}
+	return &SecureNoteData{
+		CipherData: e.EncryptedCipherData.decrypt(c),
+		Type:       e.Type,
+	}
+}
+
+func (d *SshKeyData) Encrypt(key CryptoKey) (*EncryptedSshKeyData, error) {
+	c := cryptor{key: key}
+	e := d.encrypt(&c)
+	return e, c.err()
+}
+
+func (d *SshKeyData) encrypt(c *cryptor) *EncryptedSshKeyData {
+	if d == nil {
+		return nil
+	}
+	return &EncryptedSshKeyData{
+		EncryptedCipherData: d.CipherData.encrypt(c),
+		PrivateKey:          c.encryptPtr("PrivateKey", d.PrivateKey),
+		PublicKey:           c.encryptPtr("PublicKey", d.PublicKey),
+		KeyFingerprint:      c.encryptPtr("KeyFingerprint", d.KeyFingerprint),
+	}
+}
+
+func (e *EncryptedSshKeyData) Decrypt(key CryptoKey) (*SshKeyData, error) {
+	c := cryptor{key: key}
+	d := e.decrypt(&c)
+	return d, c.err()
+}
+
+func (e *EncryptedSshKeyData) decrypt(c *cryptor) *SshKeyData {
+	if e == nil {
+		return nil
+	}
+	return &SshKeyData{
+		CipherData:     e.EncryptedCipherData.decrypt(c),
+		PrivateKey:     c.decryptPtr("PrivateKey", e.PrivateKey),
+		PublicKey:      c.decryptPtr("PublicKey", e.PublicKey),
+		KeyFingerprint: c.decryptPtr("KeyFingerprint", e.KeyFingerprint),
+	}
+}
+
+func encryptPasswordHistory(c *cryptor, history []PasswordHistoryData) []EncryptedPasswordHistoryData {
+	if history == nil {
+		return nil
+	}
+	e := make([]EncryptedPasswordHistoryData, len(history))
+	for i, h := range history {
+		e[i] = EncryptedPasswordHistoryData{
+			Password:     c.encrypt(fmt.Sprintf("PasswordHistory[%d].Password", i), h.Password),
+			LastUsedDate: h.LastUsedDate,
+		}
+	}
+	return e
+}
+
+func decryptPasswordHistory(c *cryptor, history []EncryptedPasswordHistoryData) []PasswordHistoryData {
+	if history == nil {
+		return nil
+	}
+	d := make([]PasswordHistoryData, len(history))
+	for i, h := range history {
+		d[i] = PasswordHistoryData{
+			Password:     c.decrypt(fmt.Sprintf("PasswordHistory[%d].Password", i), h.Password),
+			LastUsedDate: h.LastUsedDate,
+		}
+	}
+	return d
+}
@@ -0,0 +1,110 @@
+package bitwarden
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrUnauthorized      = errors.New("bitwarden: unauthorized")
+	ErrNotFound          = errors.New("bitwarden: not found")
+	ErrRateLimited       = errors.New("bitwarden: rate limited")
+	ErrTwoFactorRequired = errors.New("bitwarden: two-factor authentication required")
+
+	// ErrUnknownCipherType is returned for ciphers of types this package
+	// can't handle, for example when sending them to the server.
+	ErrUnknownCipherType = errors.New("bitwarden: unknown cipher type")
+
+	// ErrUriChecksum is returned for login URIs that don't match their
+	// checksum, for example because they were swapped on the server.
+	ErrUriChecksum = errors.New("bitwarden: URI checksum mismatch")
+)
+
+// maxErrorBodySize limits how much of an error response is read.
+const maxErrorBodySize = 1 << 20
+
+// HttpErrorResponse is returned for every non-2xx response. Use errors.Is
+// with ErrUnauthorized, ErrNotFound, ErrRateLimited or ErrTwoFactorRequired
+// to test for the common cases.
+type HttpErrorResponse struct {
+	HttpResponse *http.Response
+	StatusCode   int
+	ErrorResponse
+}
+
+// Error implements the error interface.
+func (r *HttpErrorResponse) Error() string {
+	s := fmt.Sprintf("%d %v", r.StatusCode, r.Message)
+	if r.HttpResponse != nil && r.HttpResponse.Request != nil {
+		s = fmt.Sprintf("%v %v: %s", r.HttpResponse.Request.Method, r.HttpResponse.Request.URL, s)
+	}
+
+	fields := make([]string, 0, len(r.ValidationErrors))
+	for f := range r.ValidationErrors {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	for _, f := range fields {
+		msg := strings.Join(r.ValidationErrors[f], ", ")
+		if f == "" {
+			s += "; " + msg
+		} else {
+			s += "; " + f + ": " + msg
+		}
+	}
+	return s
+}
+
+// Is reports whether target is the sentinel error matching this response.
+func (r *HttpErrorResponse) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return r.StatusCode == http.StatusUnauthorized
+	case ErrNotFound:
+		return r.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return r.StatusCode == http.StatusTooManyRequests
+	case ErrTwoFactorRequired:
+		return len(r.TwoFactorProviders2) > 0
+	}
+	return false
+}
+
+// CheckResponse returns nil for 2xx responses and a *HttpErrorResponse
+// otherwise. Bodies that aren't JSON, such as the HTML error page of a proxy,
+// are tolerated.
+func CheckResponse(resp *http.Response) error {
+	if code := resp.StatusCode; 200 <= code && code <= 299 {
+		return nil
+	}
+
+	errorResponse := &HttpErrorResponse{HttpResponse: resp, StatusCode: resp.StatusCode}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if json.Unmarshal(body, &errorResponse.ErrorResponse) != nil {
+		errorResponse.ErrorResponse = ErrorResponse{}
+		if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt == "text/plain" {
+			errorResponse.Message = strings.TrimSpace(string(body))
+		}
+	}
+
+	switch {
+	case errorResponse.Message != "":
+	case errorResponse.ErrorModel != nil && errorResponse.ErrorModel.Message != "":
+		errorResponse.Message = errorResponse.ErrorModel.Message
+	case errorResponse.OAuthErrorDesc != "":
+		errorResponse.Message = errorResponse.OAuthErrorDesc
+	case errorResponse.OAuthError != "":
+		errorResponse.Message = errorResponse.OAuthError
+	default:
+		errorResponse.Message = http.StatusText(resp.StatusCode)
+	}
+
+	return errorResponse
+}
@@ -0,0 +1,74 @@
+package bitwarden
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newTestResponse(code int, contentType string, body string) *http.Response {
+	return &http.Response{
+		StatusCode: code,
+		Header:     http.Header{"Content-Type": {contentType}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	tests := []struct {
+		resp     *http.Response
+		message  string
+		sentinel error
+	}{
+		{newTestResponse(200, "application/json", `{}`), "", nil},
+		{newTestResponse(400, "application/json", `{"message":"The model state is invalid.","validationErrors":{"Name":["The field Name is required.","Too short."]},"object":"error"}`), "The model state is invalid.", nil},
+		{newTestResponse(401, "application/json", ``), "Unauthorized", ErrUnauthorized},
+		{newTestResponse(404, "application/json", `{"message":"Resource not found."}`), "Resource not found.", ErrNotFound},
+		{newTestResponse(429, "text/plain; charset=utf-8", "Slow down\n"), "Slow down", ErrRateLimited},
+		{newTestResponse(502, "text/html", `<html><body><h1>502 Bad Gateway</h1></body></html>`), "Bad Gateway", nil},
+		{newTestResponse(400, "application/json", `{"error":"invalid_grant","error_description":"invalid_username_or_password","ErrorModel":{"Message":"Username or password is incorrect. Try again.","Object":"error"}}`), "Username or password is incorrect. Try again.", nil},
+		{newTestResponse(400, "application/json", `{"error":"invalid_grant","error_description":"Two factor required.","TwoFactorProviders":["0"],"TwoFactorProviders2":{"0":null}}`), "Two factor required.", ErrTwoFactorRequired},
+	}
+
+	for _, test := range tests {
+		err := CheckResponse(test.resp)
+		if test.resp.StatusCode == 200 {
+			if err != nil {
+				t.Errorf("Expected no error got %v", err)
+			}
+			continue
+		}
+
+		var herr *HttpErrorResponse
+		if !errors.As(err, &herr) {
+			t.Fatalf("Expected *HttpErrorResponse got %T", err)
+		}
+		if herr.StatusCode != test.resp.StatusCode {
+			t.Errorf("Expected %v got %v", test.resp.StatusCode, herr.StatusCode)
+		}
+		if herr.Message != test.message {
+			t.Errorf("Expected %q got %q", test.message, herr.Message)
+		}
+		for _, sentinel := range []error{ErrUnauthorized, ErrNotFound, ErrRateLimited, ErrTwoFactorRequired} {
+			if errors.Is(err, sentinel) != (sentinel == test.sentinel) {
+				t.Errorf("%d: errors.Is(%v) = %v", herr.StatusCode, sentinel, !(sentinel == test.sentinel))
+			}
+		}
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	err := CheckResponse(newTestResponse(400, "application/json", `{"message":"The model state is invalid.","validationErrors":{"Name":["The field Name is required.","Too short."],"":["Invalid."]}}`))
+
+	herr := err.(*HttpErrorResponse)
+	if len(herr.ValidationErrors["Name"]) != 2 {
+		t.Errorf("Expected 2 validation errors got %v", herr.ValidationErrors["Name"])
+	}
+
+	expected := "400 The model state is invalid.; Invalid.; Name: The field Name is required., Too short."
+	if err.Error() != expected {
+		t.Errorf("Expected %q got %q", expected, err.Error())
+	}
+}
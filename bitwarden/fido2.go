@@ -0,0 +1,136 @@
+package bitwarden
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Authenticator data flags, see the WebAuthn specification.
+const (
+	fido2FlagUserPresent  = 0x01
+	fido2FlagUserVerified = 0x04
+)
+
+// Fido2Assertion is a WebAuthn assertion, the response of an authenticator
+// to navigator.credentials.get().
+type Fido2Assertion struct {
+	CredentialId      []byte
+	AuthenticatorData []byte
+	ClientDataJSON    []byte
+	Signature         []byte // ASN.1 DER encoded ECDSA signature
+	UserHandle        []byte
+}
+
+type fido2ClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// Assert signs challenge with a decrypted passkey like an authenticator
+// would, with the user present and verified. It is meant for testing
+// relying parties. The counter of the credential is incremented, so the
+// cipher should be saved afterwards. Once the counter reaches its 32 bit
+// limit, Assert fails.
+func (f *Fido2CredentialData) Assert(origin string, challenge []byte) (*Fido2Assertion, error) {
+	if f.KeyAlgorithm != "ECDSA" || f.KeyCurve != "P-256" {
+		return nil, fmt.Errorf("bitwarden: unsupported passkey algorithm %s %s", f.KeyAlgorithm, f.KeyCurve)
+	}
+	key, err := f.privateKey()
+	if err != nil {
+		return nil, err
+	}
+	credentialId, err := f.credentialId()
+	if err != nil {
+		return nil, err
+	}
+	userHandle, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(f.UserHandle, "="))
+	if err != nil {
+		return nil, fmt.Errorf("bitwarden: invalid passkey user handle: %w", err)
+	}
+	counter := uint64(0)
+	if f.Counter != "" {
+		counter, err = strconv.ParseUint(f.Counter, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("bitwarden: invalid passkey counter: %w", err)
+		}
+	}
+	// The counter in the authenticator data has 32 bits and must not wrap.
+	if counter == math.MaxUint32 {
+		return nil, errors.New("bitwarden: passkey counter exhausted")
+	}
+	counter++
+
+	clientData, err := json.Marshal(fido2ClientData{
+		Type:      "webauthn.get",
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:    origin,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rpIdHash := sha256.Sum256([]byte(f.RpId))
+	authData := make([]byte, 0, len(rpIdHash)+1+4)
+	authData = append(authData, rpIdHash[:]...)
+	authData = append(authData, fido2FlagUserPresent|fido2FlagUserVerified)
+	authData = binary.BigEndian.AppendUint32(authData, uint32(counter))
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	f.Counter = strconv.FormatUint(counter, 10)
+	return &Fido2Assertion{
+		CredentialId:      credentialId,
+		AuthenticatorData: authData,
+		ClientDataJSON:    clientData,
+		Signature:         sig,
+		UserHandle:        userHandle,
+	}, nil
+}
+
+func (f *Fido2CredentialData) privateKey() (*ecdsa.PrivateKey, error) {
+	der, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(f.KeyValue, "="))
+	if err != nil {
+		return nil, fmt.Errorf("bitwarden: invalid passkey private key: %w", err)
+	}
+	k, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("bitwarden: invalid passkey private key: %w", err)
+	}
+	key, ok := k.(*ecdsa.PrivateKey)
+	if !ok || key.Curve != elliptic.P256() {
+		return nil, errors.New("bitwarden: passkey private key is not a P-256 key")
+	}
+	return key, nil
+}
+
+// credentialId returns the raw credential id. Bitwarden stores ids it
+// created as GUIDs, imported ones with a "b64." prefix.
+func (f *Fido2CredentialData) credentialId() ([]byte, error) {
+	if strings.HasPrefix(f.CredentialId, "b64.") {
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(f.CredentialId[4:], "="))
+	}
+	id, err := hex.DecodeString(strings.ReplaceAll(f.CredentialId, "-", ""))
+	if err != nil || len(id) != 16 {
+		return nil, fmt.Errorf("bitwarden: invalid passkey credential id %q", f.CredentialId)
+	}
+	return id, nil
+}
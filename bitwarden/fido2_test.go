@@ -0,0 +1,138 @@
+package bitwarden
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testFido2Credential(t *testing.T) (Fido2CredentialData, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Fido2CredentialData{
+		CredentialId:    "6f1c2b3a-4d5e-4f60-8172-93a4b5c6d7e8",
+		KeyType:         "public-key",
+		KeyAlgorithm:    "ECDSA",
+		KeyCurve:        "P-256",
+		KeyValue:        base64.RawURLEncoding.EncodeToString(der),
+		RpId:            "example.com",
+		RpName:          "Example",
+		UserHandle:      base64.RawURLEncoding.EncodeToString([]byte("user-1")),
+		UserName:        "alice",
+		UserDisplayName: "Alice",
+		Counter:         "41",
+		Discoverable:    "true",
+		CreationDate:    Time{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}, key
+}
+
+func TestFido2CredentialRoundTrip(t *testing.T) {
+	_, userKey := testKeys(t)
+	cred, _ := testFido2Credential(t)
+	name := "Example"
+	v := CipherView{
+		Type:  CipherType_Login,
+		Login: &LoginData{CipherData: CipherData{Name: &name}, Fido2Credentials: &[]Fido2CredentialData{cred}},
+	}
+
+	c, err := v.Encrypt(userKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec := (*c.Login.Fido2Credentials)[0]
+	if ec.KeyValue.IsZero() || ec.Counter.IsZero() || ec.Discoverable.IsZero() || ec.CreationDate != cred.CreationDate {
+		t.Fatalf("Unexpected encrypted credential %+v", ec)
+	}
+
+	cr := CipherRequest{}
+	if err := cr.FromCipher(*c); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr = CipherRequest{}
+	if err := json.Unmarshal(b, &cr); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := cr.ToCipher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rv, err := rc.Decrypt(userKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rv.Login.Fido2Credentials == nil || !reflect.DeepEqual(*rv.Login.Fido2Credentials, []Fido2CredentialData{cred}) {
+		t.Errorf("Round trip changed credentials:\n%+v\n%+v", rv.Login.Fido2Credentials, cred)
+	}
+}
+
+func TestFido2Assert(t *testing.T) {
+	cred, key := testFido2Credential(t)
+	challenge := []byte("challenge")
+
+	a, err := cred.Assert("https://example.com", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpIdHash := sha256.Sum256([]byte("example.com"))
+	if len(a.AuthenticatorData) != 37 || !bytes.Equal(a.AuthenticatorData[:32], rpIdHash[:]) || a.AuthenticatorData[32] != 0x05 {
+		t.Errorf("Unexpected authenticator data %x", a.AuthenticatorData)
+	}
+	if n := binary.BigEndian.Uint32(a.AuthenticatorData[33:]); n != 42 || cred.Counter != "42" {
+		t.Errorf("Expected counter 42, got %d and %s", n, cred.Counter)
+	}
+	if string(a.UserHandle) != "user-1" || len(a.CredentialId) != 16 || a.CredentialId[0] != 0x6f {
+		t.Errorf("Unexpected assertion %+v", a)
+	}
+
+	var cd fido2ClientData
+	if err := json.Unmarshal(a.ClientDataJSON, &cd); err != nil {
+		t.Fatal(err)
+	}
+	if cd.Type != "webauthn.get" || cd.Origin != "https://example.com" || cd.Challenge != base64.RawURLEncoding.EncodeToString(challenge) {
+		t.Errorf("Unexpected client data %s", a.ClientDataJSON)
+	}
+
+	clientDataHash := sha256.Sum256(a.ClientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), a.AuthenticatorData...), clientDataHash[:]...))
+	if !ecdsa.VerifyASN1(&key.PublicKey, digest[:], a.Signature) {
+		t.Error("Signature doesn't verify")
+	}
+
+	cred.Counter = "4294967294"
+	if a, err := cred.Assert("https://example.com", challenge); err != nil || binary.BigEndian.Uint32(a.AuthenticatorData[33:]) != 4294967295 {
+		t.Errorf("Unexpected assertion at the counter limit: %v", err)
+	}
+	if _, err := cred.Assert("https://example.com", challenge); err == nil || cred.Counter != "4294967295" {
+		t.Errorf("Expected error for exhausted counter, got %v and %s", err, cred.Counter)
+	}
+	cred.Counter = "42"
+
+	cred.KeyCurve = "P-384"
+	if _, err := cred.Assert("https://example.com", challenge); err == nil {
+		t.Error("Expected error for unsupported curve")
+	}
+	cred.KeyCurve = "P-256"
+	cred.CredentialId = "b64." + base64.RawURLEncoding.EncodeToString([]byte("imported"))
+	if a, err := cred.Assert("https://example.com", challenge); err != nil || string(a.CredentialId) != "imported" {
+		t.Errorf("Unexpected imported credential id: %v", err)
+	}
+}
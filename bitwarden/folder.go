@@ -1,5 +1,7 @@
 package bitwarden
 
+import "context"
+
 const (
 	PATH_FOLDERS = "folders"
 )
@@ -8,10 +10,13 @@ type FolderService struct {
 	client *Client
 }
 
-func (c *FolderService) ListFolders() ([]Folder, error) {
-	req, err := c.client.newRequest("GET", PATH_FOLDERS, nil)
+func (c *FolderService) ListFolders(ctx context.Context) ([]EncryptedFolder, error) {
+	req, err := c.client.newRequest(ctx, "GET", PATH_FOLDERS, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	folders := make([]Folder, 0)
+	folders := make([]EncryptedFolder, 0)
 	data := List{Data: &folders}
 	_, err = c.client.do(req, &data)
 	if err != nil {
@@ -21,10 +26,30 @@ func (c *FolderService) ListFolders() ([]Folder, error) {
 	return folders, err
 }
 
-func (c *FolderService) AddFolder(folder *Folder) (*Folder, error) {
-	req, err := c.client.newRequest("POST", PATH_FOLDERS, folder)
+// GetFolder returns a folder by id. An error matching ErrNotFound is
+// returned if it doesn't exist.
+func (c *FolderService) GetFolder(ctx context.Context, id string) (*EncryptedFolder, error) {
+	req, err := c.client.newRequest(ctx, "GET", PATH_FOLDERS+"/"+id, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	f := Folder{}
+	f := EncryptedFolder{}
+	_, err = c.client.do(req, &f)
+	if err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+func (c *FolderService) AddFolder(ctx context.Context, folder *EncryptedFolder) (*EncryptedFolder, error) {
+	req, err := c.client.newRequest(ctx, "POST", PATH_FOLDERS, folder)
+	if err != nil {
+		return nil, err
+	}
+
+	f := EncryptedFolder{}
 	_, err = c.client.do(req, &f)
 	if err != nil {
 		return nil, err
@@ -33,10 +58,13 @@ func (c *FolderService) AddFolder(folder *Folder) (*Folder, error) {
 	return &f, err
 }
 
-func (c *FolderService) UpdateFolder(folder *Folder) (*Folder, error) {
-	req, err := c.client.newRequest("PUT", PATH_FOLDERS+"/"+folder.Id, folder)
+func (c *FolderService) UpdateFolder(ctx context.Context, folder *EncryptedFolder) (*EncryptedFolder, error) {
+	req, err := c.client.newRequest(ctx, "PUT", PATH_FOLDERS+"/"+folder.Id, folder)
+	if err != nil {
+		return nil, err
+	}
 
-	f := Folder{}
+	f := EncryptedFolder{}
 	_, err = c.client.do(req, &f)
 	if err != nil {
 		return nil, err
@@ -45,10 +73,13 @@ func (c *FolderService) UpdateFolder(folder *Folder) (*Folder, error) {
 	return &f, nil
 }
 
-func (c *FolderService) DeleteFolder(folder *Folder) (*Folder, error) {
-	req, err := c.client.newRequest("DELETE", PATH_FOLDERS+"/"+folder.Id, folder)
+func (c *FolderService) DeleteFolder(ctx context.Context, folder *EncryptedFolder) (*EncryptedFolder, error) {
+	req, err := c.client.newRequest(ctx, "DELETE", PATH_FOLDERS+"/"+folder.Id, folder)
+	if err != nil {
+		return nil, err
+	}
 
-	f := Folder{}
+	f := EncryptedFolder{}
 	_, err = c.client.do(req, &f)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,125 @@
+package bitwarden
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// fuzzKey is a fixed key, so that the fuzzer can keep inputs that decrypt.
+func fuzzKey(f *testing.F) CryptoKey {
+	k, err := NewCryptoKey(bytes.Repeat([]byte{7}, 64), AesCbc256_HmacSha256_B64)
+	if err != nil {
+		f.Fatal(err)
+	}
+	return k
+}
+
+func FuzzCipherResponse(f *testing.F) {
+	key := fuzzKey(f)
+	name, user := "Example", "alice"
+	v := CipherView{Type: CipherType_Login, Login: &LoginData{CipherData: CipherData{Name: &name}, Username: &user}}
+	c, err := v.Encrypt(key)
+	if err != nil {
+		f.Fatal(err)
+	}
+	b, err := json.Marshal(NewCipherDetailsResponse(*c))
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(b)
+	f.Add([]byte(testCipherDetails))
+	f.Add([]byte(testUnknownCipher))
+	f.Add([]byte(`{"type":1,"data":null}`))
+	f.Add([]byte(`{"type":3,"data":{"name":"2.x|y"},"key":"2.AAAA|AAAA|AAAA"}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cdr := CipherDetailsResponse{}
+		if json.Unmarshal(data, &cdr) != nil {
+			return
+		}
+		c, err := cdr.ToCipher()
+		if err != nil {
+			return
+		}
+		if v, err := c.Decrypt(key); v != nil {
+			v.Encrypt(key)
+		} else if err == nil {
+			t.Fatal("Expected cipher or error")
+		}
+		json.Marshal(NewCipherDetailsResponse(c))
+		cr := CipherRequest{}
+		if cr.FromCipher(c) == nil {
+			cr.ToCipher()
+		}
+	})
+}
+
+func FuzzSync(f *testing.F) {
+	key := fuzzKey(f)
+	f.Add([]byte(`{"Profile":{},"Folders":[{"Id":"f1","Name":"2.AAAA|AAAA|AAAA"}],"Ciphers":[` + testCipherDetails + `,` + testUnknownCipher + `]}`))
+	f.Add([]byte(`{"Ciphers":[{"type":2},{"type":-1,"data":"x"}]}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var sync SyncData
+		if json.Unmarshal(data, &sync) != nil {
+			return
+		}
+		for _, folder := range sync.Folders {
+			folder.Decrypt(key)
+		}
+		for _, cdr := range sync.Ciphers {
+			if c, err := cdr.ToCipher(); err == nil {
+				c.Decrypt(key)
+			}
+		}
+	})
+}
+
+func FuzzCipherString(f *testing.F) {
+	key := fuzzKey(f)
+	cs, err := Encrypt(bytes.Repeat([]byte{1}, 64), key)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(cs.String())
+	f.Add(testUserKey)
+	f.Add("0.AAAA|AAAA")
+	f.Add("4.AAAA")
+	f.Add("6.AAAA|AAAA")
+	f.Add("2.|||")
+	f.Add("x.y")
+	f.Add("AAAA|AAAA|AAAA")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		var u CipherString
+		if u.UnmarshalText([]byte(s)) != nil || (u.Err() != nil && u.String() != s) {
+			t.Fatal("Expected invalid cipher string to be kept as is")
+		}
+		cs, err := NewCipherString(s)
+		if err != nil {
+			return
+		}
+		cs.Decrypt(key)
+		cs.DecryptKey(key, AesCbc256_HmacSha256_B64)
+		cs.DecryptUserKey(key)
+		DecryptValue(s, key)
+	})
+}
+
+func FuzzCheckResponse(f *testing.F) {
+	f.Add(400, "application/json", `{"message":"The model state is invalid.","validationErrors":{"Name":["Required"]}}`)
+	f.Add(401, "application/json", `{"error":"invalid_grant","error_description":"invalid_username_or_password"}`)
+	f.Add(502, "text/plain; charset=utf-8", "Bad Gateway")
+	f.Add(500, "text/html", "<html>")
+
+	f.Fuzz(func(t *testing.T, code int, contentType string, body string) {
+		resp := &http.Response{StatusCode: code, Header: http.Header{"Content-Type": {contentType}}, Body: io.NopCloser(strings.NewReader(body))}
+		if err := CheckResponse(resp); err != nil {
+			_ = err.Error()
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package bitwarden
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+)
+
+// KeyProvider returns the symmetric key protecting items owned by the user,
+// when organizationId is nil, or by the given organization.
+type KeyProvider interface {
+	KeyFor(organizationId *string) (CryptoKey, error)
+}
+
+// KeyFor returns k for the user's own items and fails for items of an
+// organization. Use a KeyRing to decrypt items shared through organizations.
+func (k CryptoKey) KeyFor(organizationId *string) (CryptoKey, error) {
+	if organizationId != nil {
+		return CryptoKey{}, fmt.Errorf("bitwarden: no key for organization %s", *organizationId)
+	}
+	return k, nil
+}
+
+// KeyRing holds the user's keys and the keys of the user's organizations.
+type KeyRing struct {
+	UserKey          CryptoKey
+	PrivateKey       *rsa.PrivateKey
+	OrganizationKeys map[string]CryptoKey
+
+	// organizationErrors keeps why AddOrganizations couldn't add a key.
+	organizationErrors map[string]error
+}
+
+func (kr *KeyRing) KeyFor(organizationId *string) (CryptoKey, error) {
+	if organizationId == nil {
+		return kr.UserKey, nil
+	}
+	k, ok := kr.OrganizationKeys[*organizationId]
+	if !ok {
+		if err := kr.organizationErrors[*organizationId]; err != nil {
+			return CryptoKey{}, fmt.Errorf("no key for organization %s: %w", *organizationId, err)
+		}
+		return CryptoKey{}, fmt.Errorf("no key for organization %s", *organizationId)
+	}
+	return k, nil
+}
+
+// AddOrganizationKey decrypts the organization key, which is encrypted with
+// the user's public key, and adds it to the key ring.
+func (kr *KeyRing) AddOrganizationKey(organizationId string, encryptedKey CipherString) error {
+	if kr.PrivateKey == nil {
+		return errors.New("no private key to decrypt organization key")
+	}
+	kb, err := encryptedKey.DecryptWithPrivateKey(kr.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("organization %s: %v", organizationId, err)
+	}
+	k, err := NewCryptoKey(kb, AesCbc256_HmacSha256_B64)
+	if err != nil {
+		return fmt.Errorf("organization %s: %v", organizationId, err)
+	}
+
+	if kr.OrganizationKeys == nil {
+		kr.OrganizationKeys = make(map[string]CryptoKey)
+	}
+	kr.OrganizationKeys[organizationId] = k
+	delete(kr.organizationErrors, organizationId)
+	return nil
+}
+
+// AddOrganizations adds the keys of all organizations with a confirmed
+// membership. Organizations whose key fails to decrypt are skipped, their
+// errors are returned joined and by KeyFor for their items.
+func (kr *KeyRing) AddOrganizations(orgs []ProfileOrganizationResponse) error {
+	var errs []error
+	for _, org := range orgs {
+		if org.Status != OrganizationUserStatus_Confirmed || org.Key.IsZero() {
+			continue
+		}
+		if err := kr.AddOrganizationKey(org.Id, org.Key); err != nil {
+			if kr.organizationErrors == nil {
+				kr.organizationErrors = make(map[string]error)
+			}
+			kr.organizationErrors[org.Id] = err
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// wipe overwrites all key material held by the key ring.
+func (kr *KeyRing) wipe() {
+	zero(kr.UserKey.EncKey)
+	zero(kr.UserKey.MacKey)
+	kr.UserKey = CryptoKey{}
+	if kr.PrivateKey != nil {
+		zeroInt(kr.PrivateKey.D)
+		for _, p := range kr.PrivateKey.Primes {
+			zeroInt(p)
+		}
+		zeroInt(kr.PrivateKey.Precomputed.Dp)
+		zeroInt(kr.PrivateKey.Precomputed.Dq)
+		zeroInt(kr.PrivateKey.Precomputed.Qinv)
+		kr.PrivateKey = nil
+	}
+	for id, k := range kr.OrganizationKeys {
+		zero(k.EncKey)
+		zero(k.MacKey)
+		delete(kr.OrganizationKeys, id)
+	}
+	kr.organizationErrors = nil
+}
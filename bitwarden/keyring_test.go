@@ -0,0 +1,256 @@
+package bitwarden
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	testRSAKeyOnce sync.Once
+	testRSAKey     *rsa.PrivateKey
+	testRSAKeyErr  error
+)
+
+// newTestRSAKey returns an RSA key shared by all tests, wiping it is not
+// allowed.
+func newTestRSAKey(t *testing.T) *rsa.PrivateKey {
+	testRSAKeyOnce.Do(func() {
+		testRSAKey, testRSAKeyErr = rsa.GenerateKey(rand.Reader, 2048)
+	})
+	if testRSAKeyErr != nil {
+		t.Fatal(testRSAKeyErr)
+	}
+	return testRSAKey
+}
+
+func TestRSACipherString(t *testing.T) {
+	priv := newTestRSAKey(t)
+	pt := []byte("organization key")
+
+	ct256, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, &priv.PublicKey, pt, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs1, err := EncryptWithPublicKey(pt, &priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.StdEncoding.EncodeToString(ct256)
+
+	for _, s := range []string{
+		cs1.String(),
+		"3." + b64,
+		"5." + b64 + "|bWFj",
+	} {
+		cs, err := NewCipherString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cs.String() != s {
+			t.Errorf("Expected %v got %v", s, cs.String())
+		}
+		d, err := cs.DecryptWithPrivateKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(d) != string(pt) {
+			t.Errorf("Expected %v got %v", string(pt), string(d))
+		}
+		if _, err := cs.Decrypt(CryptoKey{EncKey: make([]byte, 32)}); err == nil {
+			t.Error("Expected error decrypting RSA cipher string with AES key")
+		}
+	}
+
+	for _, s := range []string{"3." + b64 + "|bWFj", "4.", "6." + b64} {
+		if _, err := NewCipherString(s); err == nil {
+			t.Errorf("Expected error for %v", s)
+		}
+	}
+}
+
+func TestKeyRing(t *testing.T) {
+	_, userKey := testKeys(t)
+	priv := newTestRSAKey(t)
+
+	orgKey := make([]byte, 64)
+	rand.Read(orgKey)
+	encOrgKey, err := EncryptWithPublicKey(orgKey, &priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kr := &KeyRing{UserKey: userKey, PrivateKey: priv}
+	err = kr.AddOrganizations([]ProfileOrganizationResponse{
+		{Id: "org", Key: encOrgKey, Status: OrganizationUserStatus_Confirmed},
+		{Id: "invited", Status: OrganizationUserStatus_Invited},
+		{Id: "accepted", Key: encOrgKey, Status: OrganizationUserStatus_Accepted},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kr.OrganizationKeys) != 1 {
+		t.Errorf("Expected 1 organization key got %d", len(kr.OrganizationKeys))
+	}
+	ok, err := NewCryptoKey(orgKey, AesCbc256_HmacSha256_B64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orgId := "org"
+	name := "shared"
+	v := CipherView{Type: CipherType_Login, OrganizationId: &orgId, Login: &LoginData{CipherData: CipherData{Name: &name}}}
+	if _, err := v.Encrypt(userKey); err == nil || !strings.Contains(err.Error(), "no key for organization org") {
+		t.Errorf("Expected a bare key to have no key for organization got %v", err)
+	}
+	c, err := v.Encrypt(&KeyRing{OrganizationKeys: map[string]CryptoKey{orgId: ok}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Login.Name.Decrypt(userKey); err == nil {
+		t.Error("Expected organization cipher not to decrypt with user key")
+	}
+	dv, err := c.Decrypt(kr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *dv.Login.Name != name {
+		t.Errorf("Expected %v got %v", name, *dv.Login.Name)
+	}
+
+	unknown := "unknown"
+	if _, err := kr.KeyFor(&unknown); err == nil {
+		t.Error("Expected error for unknown organization")
+	}
+}
+
+func TestCipherItemKey(t *testing.T) {
+	_, userKey := testKeys(t)
+	orgKey, err := NewCryptoKey(bytes.Repeat([]byte{7}, 64), AesCbc256_HmacSha256_B64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kr := &KeyRing{UserKey: userKey, OrganizationKeys: map[string]CryptoKey{"org1": orgKey}}
+
+	newCipher := func() CipherView {
+		name := "Item"
+		return CipherView{Type: CipherType_SecureNote, SecureNote: &SecureNoteData{CipherData: CipherData{Name: &name}}}
+	}
+	c := newCipher()
+	if err := c.NewKey(kr); err != nil {
+		t.Fatal(err)
+	}
+	if c.Key == nil {
+		t.Fatal("Expected an item key")
+	}
+	attachmentKey := bytes.Repeat([]byte{1}, 64)
+	itemKey, err := c.key(kr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ak, err := Encrypt(attachmentKey, itemKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Attachments = []AttachmentView{{Id: "a1", FileName: "file.txt", Key: ak}}
+
+	ec, err := c.Encrypt(kr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ec.SecureNote.Name.Decrypt(userKey); err == nil {
+		t.Error("Expected name to be encrypted with the item key")
+	}
+	dc, err := ec.Decrypt(kr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = *dc
+	if *c.SecureNote.Name != "Item" || c.Attachments[0].FileName != "file.txt" {
+		t.Errorf("Unexpected cipher %+v", c)
+	}
+
+	oldKey := *c.Key
+	if err := c.MoveToOrganization(kr, "org1"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Key.String() == oldKey.String() || *c.OrganizationId != "org1" {
+		t.Fatalf("Expected a new item key for the organization")
+	}
+	itemKey, err = c.key(kr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kb, err := c.Attachments[0].Key.Decrypt(itemKey); err != nil || !bytes.Equal(kb, attachmentKey) {
+		t.Errorf("Attachment key not rewrapped: %v", err)
+	}
+	ec, err = c.Encrypt(kr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc, err = ec.Decrypt(kr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *dc.SecureNote.Name != "Item" {
+		t.Errorf("Unexpected name %q", *dc.SecureNote.Name)
+	}
+
+	// A failed move leaves the cipher as it was.
+	c = newCipher()
+	if err := c.NewKey(kr); err != nil {
+		t.Fatal(err)
+	}
+	if itemKey, err = c.key(kr); err != nil {
+		t.Fatal(err)
+	}
+	if ak, err = Encrypt(attachmentKey, itemKey); err != nil {
+		t.Fatal(err)
+	}
+	c.Attachments = []AttachmentView{{Id: "a1", FileName: "file.txt", Key: ak}, {Id: "legacy", FileName: "file.txt"}}
+	key := *c.Key
+	if err := c.MoveToOrganization(kr, "org1"); err == nil {
+		t.Error("Expected error for attachment without key")
+	}
+	if c.OrganizationId != nil || c.Key.String() != key.String() || c.Attachments[0].Key.String() != ak.String() || !c.Attachments[1].Key.IsZero() {
+		t.Errorf("Expected cipher to be unchanged got %+v", c)
+	}
+
+	s := &Session{keys: *kr, CipherKeyEncryption: true}
+	c = newCipher()
+	ec, err = s.EncryptCipher(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ec.Key == nil {
+		t.Error("Expected an item key for a new cipher")
+	}
+
+	// Moving a cipher without an item key creates one as well.
+	c = newCipher()
+	if err := s.MoveToOrganization(&c, "org1"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Key == nil || *c.OrganizationId != "org1" {
+		t.Fatal("Expected an item key for the moved cipher")
+	}
+	ec, err = c.Encrypt(kr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ec.SecureNote.Name.Decrypt(orgKey); err == nil {
+		t.Error("Expected name to be encrypted with the item key")
+	}
+	if dc, err := ec.Decrypt(kr); err != nil || *dc.SecureNote.Name != "Item" {
+		t.Errorf("Unexpected moved cipher: %v", err)
+	}
+	s.Lock()
+	if _, err := s.EncryptCipher(&c); err != ErrLocked {
+		t.Errorf("Expected ErrLocked got %v", err)
+	}
+}
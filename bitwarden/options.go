@@ -0,0 +1,107 @@
+package bitwarden
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// An Option configures a Client created by NewClient.
+type Option func(*Client) error
+
+// WithServerURL points the client at a single Bitwarden server, such as a
+// self-hosted instance at https://bitwarden.example.com/. The API, identity
+// and icons endpoints are derived from it as /api, /identity and /icons, and
+// the server URL itself is used as the web vault.
+func WithServerURL(server string) Option {
+	return func(c *Client) error {
+		u, err := parseBaseURL(server)
+		if err != nil {
+			return err
+		}
+		c.WebVaultBaseURL = u
+		c.APIBaseURL = u.ResolveReference(&url.URL{Path: "api/"})
+		c.IdentityBaseURL = u.ResolveReference(&url.URL{Path: "identity/"})
+		c.IconsBaseURL = u.ResolveReference(&url.URL{Path: "icons/"})
+		return nil
+	}
+}
+
+// WithAPIURL sets the base URL of the API endpoint.
+func WithAPIURL(api string) Option {
+	return func(c *Client) (err error) {
+		c.APIBaseURL, err = parseBaseURL(api)
+		return err
+	}
+}
+
+// WithIdentityURL sets the base URL of the identity endpoint.
+func WithIdentityURL(identity string) Option {
+	return func(c *Client) (err error) {
+		c.IdentityBaseURL, err = parseBaseURL(identity)
+		return err
+	}
+}
+
+// WithIconsURL sets the base URL of the icons endpoint.
+func WithIconsURL(icons string) Option {
+	return func(c *Client) (err error) {
+		c.IconsBaseURL, err = parseBaseURL(icons)
+		return err
+	}
+}
+
+// WithWebVaultURL sets the base URL of the web vault.
+func WithWebVaultURL(webVault string) Option {
+	return func(c *Client) (err error) {
+		c.WebVaultBaseURL, err = parseBaseURL(webVault)
+		return err
+	}
+}
+
+// WithUserAgent overrides the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) error {
+		c.UserAgent = userAgent
+		return nil
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for all requests, including the
+// token requests made during authentication.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) error {
+		if httpClient == nil {
+			return errors.New("nil http client")
+		}
+		c.httpClient = httpClient
+		return nil
+	}
+}
+
+// WithDebugLogger enables Debug and writes the debugging logs to logger.
+func WithDebugLogger(logger *log.Logger) Option {
+	return func(c *Client) error {
+		c.Debug = true
+		c.logger = logger
+		return nil
+	}
+}
+
+// parseBaseURL parses s and makes sure the path ends with a slash, so that
+// relative paths resolve below it instead of replacing the last segment.
+func parseBaseURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("invalid base url: " + s)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u, nil
+}
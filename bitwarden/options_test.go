@@ -0,0 +1,57 @@
+package bitwarden
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithServerURL(t *testing.T) {
+	c, err := NewClient(WithServerURL("https://bitwarden.example.com/vault"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"https://bitwarden.example.com/vault/":          c.WebVaultBaseURL.String(),
+		"https://bitwarden.example.com/vault/api/":      c.APIBaseURL.String(),
+		"https://bitwarden.example.com/vault/identity/": c.IdentityBaseURL.String(),
+		"https://bitwarden.example.com/vault/icons/":    c.IconsBaseURL.String(),
+	}
+	for e, u := range expected {
+		if e != u {
+			t.Errorf("Expected %v got %v", e, u)
+		}
+	}
+
+	_, err = NewClient(WithServerURL("bitwarden.example.com"))
+	if err == nil {
+		t.Error("Expected error for URL without scheme")
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	var userAgent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.UserAgent()
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	for ua, opt := range map[string]Option{
+		defaultUserAgent: WithDebugLogger(nil),
+		"custom/1.0":     WithUserAgent("custom/1.0"),
+	} {
+		c, err := NewClient(WithAPIURL(ts.URL), WithHTTPClient(ts.Client()), opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Account.GetProfile(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if userAgent != ua {
+			t.Errorf("Expected %v got %v", ua, userAgent)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package bitwarden
+
+import "context"
+
+const (
+	PATH_ORGANIZATIONS = "organizations"
+)
+
+type OrganizationService struct {
+	client *Client
+}
+
+// ListOrganizations lists the organizations the user is a member of, with
+// the user's role and the organization's encrypted key.
+func (c *OrganizationService) ListOrganizations(ctx context.Context) ([]ProfileOrganizationResponse, error) {
+	req, err := c.client.newRequest(ctx, "GET", PATH_ORGANIZATIONS, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	orgs := make([]ProfileOrganizationResponse, 0)
+	data := List{Data: &orgs}
+	_, err = c.client.do(req, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return orgs, nil
+}
+
+// ListUsers lists the members of an organization. It requires the
+// permission to manage users.
+func (c *OrganizationService) ListUsers(ctx context.Context, organizationId string) ([]OrganizationUserResponse, error) {
+	req, err := c.client.newRequest(ctx, "GET", PATH_ORGANIZATIONS+"/"+organizationId+"/users", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]OrganizationUserResponse, 0)
+	data := List{Data: &users}
+	_, err = c.client.do(req, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetKeys returns the organization's own key pair.
+func (c *OrganizationService) GetKeys(ctx context.Context, organizationId string) (OrganizationKeysResponse, error) {
+	var keys OrganizationKeysResponse
+	req, err := c.client.newRequest(ctx, "GET", PATH_ORGANIZATIONS+"/"+organizationId+"/keys", nil)
+	if err != nil {
+		return keys, err
+	}
+
+	_, err = c.client.do(req, &keys)
+	return keys, err
+}
@@ -0,0 +1,64 @@
+package bitwarden
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOrganizationService(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/organizations", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[
+			{"id":"org1","name":"Confirmed","key":"4.a2V5","status":2,"type":1,"enabled":true,"usePolicies":true,"seats":10,"permissions":{"manageUsers":true},"object":"profileOrganization"},
+			{"id":"org2","name":"Invited","key":null,"status":0,"type":2,"enabled":true,"seats":null,"permissions":null,"object":"profileOrganization"}
+		],"object":"list"}`))
+	})
+	mux.HandleFunc("/organizations/org1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[{"id":"ou1","userId":"u1","type":0,"status":2,"name":"Owner","email":"owner@example.com","twoFactorEnabled":true,"object":"organizationUserUserDetails"}],"object":"list"}`))
+	})
+	mux.HandleFunc("/organizations/org1/keys", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"publicKey":"cHVi","privateKey":"2.aXY=|Y3Q=|bWFj","object":"organizationKeys"}`))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	c, err := NewClient(WithAPIURL(ts.URL), WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	orgs, err := c.Organization.ListOrganizations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orgs) != 2 {
+		t.Fatalf("Expected 2 organizations got %d", len(orgs))
+	}
+	o := orgs[0]
+	if o.Id != "org1" || o.Key.String() != "4.a2V5" || o.Status != OrganizationUserStatus_Confirmed || o.Type != OrganizationUserType_Admin ||
+		!o.UsePolicies || o.Seats == nil || *o.Seats != 10 || o.Permissions == nil || !o.Permissions.ManageUsers {
+		t.Errorf("Unexpected organization %+v", o)
+	}
+	if !orgs[1].Key.IsZero() || orgs[1].Status != OrganizationUserStatus_Invited {
+		t.Errorf("Unexpected organization %+v", orgs[1])
+	}
+
+	users, err := c.Organization.ListUsers(ctx, "org1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].Email != "owner@example.com" || users[0].Type != OrganizationUserType_Owner || !users[0].TwoFactorEnabled {
+		t.Errorf("Unexpected users %+v", users)
+	}
+
+	keys, err := c.Organization.GetKeys(ctx, "org1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.PublicKey != "cHVi" || keys.PrivateKey.String() != "2.aXY=|Y3Q=|bWFj" {
+		t.Errorf("Unexpected keys %+v", keys)
+	}
+}
@@ -0,0 +1,182 @@
+package bitwarden
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how requests failing with a network error, 429 or a
+// 5xx status are retried. Only idempotent methods are retried unless
+// RetryNonIdempotent is set.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt, zero
+	// disables retrying.
+	MaxRetries int
+	// MinBackoff is the delay before the first retry, doubling with every
+	// further retry up to MaxBackoff. Requests are not retried if the
+	// server's Retry-After exceeds MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// RetryNonIdempotent also retries POST and PATCH requests, which may then
+	// be applied twice by the server.
+	RetryNonIdempotent bool
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	MinBackoff: 500 * time.Millisecond,
+	MaxBackoff: 30 * time.Second,
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy for the client.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) error {
+		if policy.MaxRetries < 0 || policy.MinBackoff < 0 || policy.MaxBackoff < policy.MinBackoff {
+			return errors.New("invalid retry policy")
+		}
+		c.retryPolicy = policy
+		return nil
+	}
+}
+
+func (p RetryPolicy) retryable(req *http.Request, resp *http.Response, err error) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+	default:
+		if !p.RetryNonIdempotent {
+			return false
+		}
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	code := resp.StatusCode
+	return code == http.StatusTooManyRequests || (code >= 500 && code != http.StatusNotImplemented)
+}
+
+// backoff returns the delay before retry number attempt (starting at 0),
+// preferring the server's Retry-After header when present. It returns false
+// if the server asks to wait longer than MaxBackoff.
+func (p RetryPolicy) backoff(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return d, d <= p.MaxBackoff
+		}
+	}
+
+	d := p.MinBackoff
+	for i := 0; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	// Equal jitter: wait between d/2 and d.
+	if d > 1 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+	return d, true
+}
+
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		if s < 0 {
+			return 0, false
+		}
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// send performs req, retrying according to the client's retry policy.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	return c.sendWith(c.httpClient, req)
+}
+
+// sendWith is like send using hc instead of the authenticated client.
+func (c *Client) sendWith(hc *http.Client, req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		start := time.Now()
+		resp, err := hc.Do(req)
+		if err != nil {
+			c.debugf("%s %s: %v", req.Method, logURL(req.URL), logError(err, req.URL))
+		} else {
+			c.debugf("%s %s: %s (%v)", req.Method, logURL(req.URL), resp.Status, time.Since(start))
+		}
+
+		if attempt >= c.retryPolicy.MaxRetries || !c.retryPolicy.retryable(req, resp, err) {
+			return resp, err
+		}
+		wait, ok := c.retryPolicy.backoff(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+			resp.Body.Close()
+		}
+		c.debugf("%s %s: retrying in %v", req.Method, logURL(req.URL), wait)
+
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// logURL strips the query and user info from u, as the signed URLs of file
+// storage carry their access token in the query.
+func logURL(u *url.URL) string {
+	v := *u
+	v.User = nil
+	v.RawQuery = ""
+	v.ForceQuery = false
+	return v.String()
+}
+
+// logError replaces the URL in the errors of http.Client with logURL.
+func logError(err error, u *url.URL) error {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		return &url.Error{Op: ue.Op, URL: logURL(u), Err: ue.Err}
+	}
+	return err
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
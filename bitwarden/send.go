@@ -0,0 +1,243 @@
+package bitwarden
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+)
+
+const (
+	PATH_SENDS = "sends"
+)
+
+type SendService struct {
+	client *Client
+}
+
+func (c *SendService) ListSends(ctx context.Context) ([]EncryptedSend, error) {
+	req, err := c.client.newRequest(ctx, "GET", PATH_SENDS, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sends := make([]EncryptedSend, 0)
+	data := List{Data: &sends}
+	_, err = c.client.do(req, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return sends, nil
+}
+
+// AddSend creates a text Send, see SendView.Encrypt.
+func (c *SendService) AddSend(ctx context.Context, send *EncryptedSend) (*EncryptedSend, error) {
+	req, err := c.client.newRequest(ctx, "POST", PATH_SENDS, send)
+	if err != nil {
+		return nil, err
+	}
+
+	s := EncryptedSend{}
+	_, err = c.client.do(req, &s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// UpdateSend updates a Send, see SendView.Encrypt.
+func (c *SendService) UpdateSend(ctx context.Context, send *EncryptedSend) (*EncryptedSend, error) {
+	req, err := c.client.newRequest(ctx, "PUT", PATH_SENDS+"/"+send.Id, send)
+	if err != nil {
+		return nil, err
+	}
+
+	s := EncryptedSend{}
+	_, err = c.client.do(req, &s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// AddFileSend creates a file Send with the content of r, see
+// SendView.Encrypt. The content is encrypted with the send key.
+func (c *SendService) AddFileSend(ctx context.Context, send *EncryptedSend, r io.Reader, keys KeyProvider) (*EncryptedSend, error) {
+	if send.Type != SendType_File || send.File == nil {
+		return nil, errors.New("bitwarden: not a file Send")
+	}
+	key, err := send.key(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := newEncryptedFile(r, key)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sreq := SendFileRequest{EncryptedSend: *send, FileLength: f.size}
+	req, err := c.client.newRequest(ctx, "POST", PATH_SENDS+"/file/v2", sreq)
+	if err != nil {
+		return nil, err
+	}
+	upload := SendFileUploadDataResponse{}
+	_, err = c.client.do(req, &upload)
+	if err != nil {
+		return nil, err
+	}
+	s := upload.SendResponse
+	if s == nil || s.File == nil {
+		return nil, errors.New("bitwarden: missing Send in upload response")
+	}
+
+	path := PATH_SENDS + "/" + s.Id + "/file/" + s.File.Id
+	err = c.client.uploadFile(ctx, upload.FileUploadType, path, upload.Url, send.File.FileName.String(), f)
+	if err != nil {
+		// Don't leave a Send without data behind.
+		c.DeleteSend(context.WithoutCancel(ctx), s.Id)
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *SendService) DeleteSend(ctx context.Context, id string) error {
+	req, err := c.client.newRequest(ctx, "DELETE", PATH_SENDS+"/"+id, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.do(req, nil)
+	return err
+}
+
+// Link returns the public link of a Send on the web vault. The key material
+// is part of the link, so the link shouldn't be handed out over insecure
+// channels.
+func (c *SendService) Link(send *EncryptedSend, keys KeyProvider) (string, error) {
+	uk, err := keys.KeyFor(nil)
+	if err != nil {
+		return "", err
+	}
+	material, err := send.Key.Decrypt(uk)
+	if err != nil {
+		return "", err
+	}
+	defer zero(material)
+	return c.client.WebVaultBaseURL.String() + "#/send/" + send.AccessId + "/" + base64.RawURLEncoding.EncodeToString(material), nil
+}
+
+// Access opens the Send behind a public link, as its recipients would. The
+// link is expected to point to the same server as the client. password is
+// only needed for password protected Sends. The Send is returned decrypted.
+// Every access counts against the Send's maximum access count.
+func (c *SendService) Access(ctx context.Context, link string, password string) (*SendAccess, error) {
+	accessId, material, err := parseSendLink(link)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(material)
+	key, err := MakeSendKey(material)
+	if err != nil {
+		return nil, err
+	}
+
+	areq := SendAccessRequest{}
+	if password != "" {
+		h := HashSendPassword(password, material)
+		areq.Password = &h
+	}
+	req, err := c.client.newRequest(ctx, "POST", PATH_SENDS+"/access/"+accessId, areq)
+	if err != nil {
+		return nil, err
+	}
+	sr := SendAccessResponse{}
+	_, err = c.client.do(req, &sr)
+	if err != nil {
+		return nil, err
+	}
+
+	cr := cryptor{key: key}
+	sa := SendAccess{
+		Id:                sr.Id,
+		Type:              sr.Type,
+		Name:              cr.decrypt("Name", sr.Name),
+		ExpirationDate:    sr.ExpirationDate,
+		CreatorIdentifier: sr.CreatorIdentifier,
+		key:               key,
+		password:          areq.Password,
+	}
+	if sr.Text != nil {
+		sa.Text = &SendText{Text: cr.decryptPtr("Text.Text", sr.Text.Text), Hidden: sr.Text.Hidden}
+	}
+	if sr.File != nil {
+		sa.File = &SendFile{Id: sr.File.Id, FileName: cr.decrypt("File.FileName", sr.File.FileName), Size: sr.File.Size, SizeName: sr.File.SizeName}
+	}
+	if err := cr.err(); err != nil {
+		return nil, err
+	}
+	return &sa, nil
+}
+
+// DownloadFile returns a reader for the decrypted content of a file Send
+// opened with Access. As for attachments, the content is authenticated at
+// the end, see AttachmentService.Download.
+func (c *SendService) DownloadFile(ctx context.Context, access *SendAccess) (io.ReadCloser, error) {
+	if access.File == nil {
+		return nil, errors.New("bitwarden: not a file Send")
+	}
+
+	areq := SendAccessRequest{Password: access.password}
+	req, err := c.client.newRequest(ctx, "POST", PATH_SENDS+"/"+access.Id+"/access/file/"+access.File.Id, areq)
+	if err != nil {
+		return nil, err
+	}
+	dl := SendFileDownloadDataResponse{}
+	_, err = c.client.do(req, &dl)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.client.downloadFile(ctx, dl.Url, access.key)
+}
+
+// key returns the send key of an encrypted Send.
+func (s *EncryptedSend) key(keys KeyProvider) (CryptoKey, error) {
+	uk, err := keys.KeyFor(nil)
+	if err != nil {
+		return CryptoKey{}, err
+	}
+	material, err := s.Key.Decrypt(uk)
+	if err != nil {
+		return CryptoKey{}, err
+	}
+	defer zero(material)
+	return MakeSendKey(material)
+}
+
+// parseSendLink returns the access id and key material from a link of the
+// form https://vault.bitwarden.com/#/send/<access id>/<key>. Links without
+// the /send/ prefix, as used by send.bitwarden.com, are accepted as well.
+func parseSendLink(link string) (string, []byte, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", nil, err
+	}
+	frag := strings.TrimPrefix(u.Fragment, "/")
+	frag = strings.TrimPrefix(frag, "send/")
+	parts := strings.Split(strings.TrimSuffix(frag, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		return "", nil, errors.New("bitwarden: invalid Send link")
+	}
+	material, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", nil, errors.New("bitwarden: invalid Send link key")
+	}
+	return parts[0], material, nil
+}
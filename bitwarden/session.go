@@ -0,0 +1,269 @@
+package bitwarden
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"strings"
+	"sync"
+)
+
+var ErrLocked = errors.New("bitwarden: vault is locked")
+
+// Session is an authenticated client together with the unlocked keys of the
+// vault. It decrypts ciphers and folders until Lock is called.
+type Session struct {
+	Client *Client
+
+	// CipherKeyEncryption makes EncryptCipher create an item key for new
+	// ciphers. Only enable it for servers that support item keys, older ones
+	// drop the key and the cipher can't be decrypted anymore.
+	CipherKeyEncryption bool
+
+	mu     sync.RWMutex
+	locked bool
+	keys   KeyRing
+}
+
+// Login logs in with email and password and unlocks the vault.
+func Login(ctx context.Context, email string, password string, opts ...Option) (*Session, error) {
+	c, dk, err := newUserPasswordAuthClient(ctx, email, password, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(dk.EncKey)
+
+	profile, err := c.Account.GetProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return unlock(c, profile, dk)
+}
+
+// Unlock unlocks the vault of an authenticated client, for example one
+// returned by NewAPIKeyAuthClient, with the master password.
+func Unlock(ctx context.Context, c *Client, password string) (*Session, error) {
+	profile, err := c.Account.GetProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dk, err := c.makeKey(ctx, strings.ToLower(strings.TrimSpace(profile.Email)), password)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(dk.EncKey)
+	return unlock(c, profile, dk)
+}
+
+// unlock decrypts the keys of profile with the master key dk.
+func unlock(c *Client, profile Account, dk CryptoKey) (*Session, error) {
+	userKey, err := profile.Key.DecryptUserKey(dk)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{Client: c, keys: KeyRing{UserKey: userKey}}
+	if !profile.PrivateKey.IsZero() {
+		s.keys.PrivateKey, err = decryptPrivateKey(profile.PrivateKey, userKey)
+		if err != nil {
+			s.Lock()
+			return nil, err
+		}
+	}
+	// A broken organization key only affects the items of that organization,
+	// decrypting them reports the error.
+	s.keys.AddOrganizations(profile.Organizations)
+	return s, nil
+}
+
+func decryptPrivateKey(encryptedKey CipherString, key CryptoKey) (*rsa.PrivateKey, error) {
+	der, err := encryptedKey.Decrypt(key)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(der)
+
+	k, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	pk, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return pk, nil
+}
+
+// Lock overwrites the key material held by the session. The keys previously
+// returned by UserKey, PrivateKey and Keys are wiped as well, and all further
+// calls needing them fail with ErrLocked.
+func (s *Session) Lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys.wipe()
+	s.locked = true
+}
+
+func (s *Session) Locked() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.locked
+}
+
+// UserKey returns the symmetric key protecting the user's vault.
+func (s *Session) UserKey() (CryptoKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.locked {
+		return CryptoKey{}, ErrLocked
+	}
+	return s.keys.UserKey, nil
+}
+
+// PrivateKey returns the user's private key, or nil if the account has none.
+func (s *Session) PrivateKey() (*rsa.PrivateKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.locked {
+		return nil, ErrLocked
+	}
+	return s.keys.PrivateKey, nil
+}
+
+// Keys returns the keys of the user and the user's organizations.
+func (s *Session) Keys() (KeyProvider, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.locked {
+		return nil, ErrLocked
+	}
+	return &s.keys, nil
+}
+
+// RefreshOrganizations fetches the user's organizations and adds their keys,
+// making ciphers of organizations joined since Unlock decryptable. The keys
+// that decrypt are added even if others fail.
+func (s *Session) RefreshOrganizations(ctx context.Context) error {
+	orgs, err := s.Client.Organization.ListOrganizations(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked {
+		return ErrLocked
+	}
+	return s.keys.AddOrganizations(orgs)
+}
+
+// Ciphers lists all ciphers and decrypts them. A cipher that fails to
+// decrypt doesn't fail the others: the views of all ciphers that decrypt at
+// least partly are returned together with the errors of the others, joined
+// with errors.Join, see CipherError and FieldError.
+func (s *Session) Ciphers(ctx context.Context) ([]CipherView, error) {
+	ciphers, err := s.Client.Cipher.ListCiphers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.locked {
+		return nil, ErrLocked
+	}
+	views := make([]CipherView, 0, len(ciphers))
+	var errs []error
+	for i := range ciphers {
+		v, err := ciphers[i].Decrypt(&s.keys)
+		if err != nil {
+			errs = append(errs, &CipherError{Id: ciphers[i].Id, Err: err})
+		}
+		if v != nil {
+			views = append(views, *v)
+		}
+	}
+	return views, errors.Join(errs...)
+}
+
+// Folders lists all folders and decrypts them. As for Ciphers, a folder that
+// fails to decrypt doesn't fail the others: the views of the others are
+// returned together with the joined errors, see FolderError.
+func (s *Session) Folders(ctx context.Context) ([]FolderView, error) {
+	folders, err := s.Client.Folder.ListFolders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.locked {
+		return nil, ErrLocked
+	}
+	views := make([]FolderView, 0, len(folders))
+	var errs []error
+	for i := range folders {
+		v, err := folders[i].Decrypt(s.keys.UserKey)
+		if err != nil {
+			errs = append(errs, &FolderError{Id: folders[i].Id, Err: err})
+			continue
+		}
+		views = append(views, *v)
+	}
+	return views, errors.Join(errs...)
+}
+
+// Collections lists the collections the user can access, with their names
+// decrypted.
+func (s *Session) Collections(ctx context.Context) ([]CollectionView, error) {
+	collections, err := s.Client.Collection.ListCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.locked {
+		return nil, ErrLocked
+	}
+	views := make([]CollectionView, len(collections))
+	for i := range collections {
+		v, err := collections[i].Decrypt(&s.keys)
+		if err != nil {
+			return nil, err
+		}
+		views[i] = *v
+	}
+	return views, nil
+}
+
+// EncryptCipher encrypts a cipher, creating an item key for new ciphers if
+// CipherKeyEncryption is enabled.
+func (s *Session) EncryptCipher(c *CipherView) (*EncryptedCipher, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.locked {
+		return nil, ErrLocked
+	}
+	if s.CipherKeyEncryption && c.Id == "" && c.Key == nil {
+		if err := c.NewKey(&s.keys); err != nil {
+			return nil, err
+		}
+	}
+	return c.Encrypt(&s.keys)
+}
+
+// MoveToOrganization prepares a cipher for sharing it with organizationId,
+// see CipherView.MoveToOrganization. Ciphers without an item key get one if
+// CipherKeyEncryption is enabled.
+func (s *Session) MoveToOrganization(c *CipherView, organizationId string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.locked {
+		return ErrLocked
+	}
+	return c.moveToOrganization(&s.keys, organizationId, s.CipherKeyEncryption)
+}
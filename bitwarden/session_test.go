@@ -0,0 +1,189 @@
+package bitwarden
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// Test account "test@example.com" with password "password", using the legacy
+// KDF settings. testUserKey is encrypted with its master key, testFolderName
+// decrypts to "test" with the user key.
+const (
+	testEmail      = "test@example.com"
+	testPassword   = "password"
+	testUserKey    = "0.yMeH5ypzRLcyJX69HAt6mQ==|H0mdMpoX1aguKIaCXOreL93JyCpo9ORiX8ZbK+taLXlGZfCb5TOs0eriKa7u1ocBp9gDHwYm5EUyobnbVfZ3uiP2suYWAXKmC4IO67b7ozc="
+	testFolderName = "2.eWiu5v/7OWt5EiuypCP9nQ==|8vxfq3AsARNjPE8rWcDLSg==|TKN0DmdhK8qjIqLe7WPpjVcAoUghGDxnpWUb4WS0jHQ="
+)
+
+func testKeys(t *testing.T) (masterKey CryptoKey, userKey CryptoKey) {
+	masterKey, err := MakeKey(testPassword, testEmail, legacyKdfConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs, err := NewCipherString(testUserKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userKey, err = cs.DecryptKey(masterKey, AesCbc256_HmacSha256_B64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return masterKey, userKey
+}
+
+// newTestVaultServer serves the test account with any login, the given
+// profile and a single folder. Prelogin requests are counted in prelogins.
+func newTestVaultServer(t *testing.T, profile string) (ts *httptest.Server, prelogins *int32) {
+	prelogins = new(int32)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/identity/connect/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"access","token_type":"Bearer","expires_in":3600}`))
+	})
+	mux.HandleFunc("/identity/accounts/prelogin", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(prelogins, 1)
+		w.Write([]byte(`{"kdf":0,"kdfIterations":5000}`))
+	})
+	mux.HandleFunc("/api/accounts/profile", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(profile))
+	})
+	mux.HandleFunc("/api/folders", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"Data":[{"Id":"1","Name":%q},{"Id":"2","Name":"7.AAAA"}],"Object":"list"}`, testFolderName)
+	})
+	mux.HandleFunc("/api/ciphers", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"Data":[{"Id":"c1","Type":2,"Data":{"Name":%q}},{"Id":"c2","Type":2,"OrganizationId":"unknown","Data":{"Name":%[1]q}},{"Id":"c3","Type":2,"Data":{"Name":%[1]q,"Notes":"4.a2V5"}}],"Object":"list"}`, testFolderName)
+	})
+
+	ts = httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts, prelogins
+}
+
+func TestSession(t *testing.T) {
+	ctx := context.Background()
+	_, userKey := testKeys(t)
+
+	rsaKey := newTestRSAKey(t)
+	der, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey, err := EncryptValue(der, userKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orgKey, err := EncryptWithPublicKey(make([]byte, 64), &rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profile := fmt.Sprintf(`{"email":%q,"key":%q,"privateKey":%q,"organizations":[{"id":"org","name":"Org","key":%q,"status":2},{"id":"broken","name":"Broken","key":"4.a2V5","status":2}]}`,
+		testEmail, testUserKey, privateKey, orgKey.String())
+	ts, prelogins := newTestVaultServer(t, profile)
+	c, err := NewAPIKeyAuthClient(ctx, "user.1234", "secret", WithServerURL(ts.URL), WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Unlock(ctx, c, "wrong")
+	if err == nil {
+		t.Error("Expected error for wrong password")
+	}
+
+	s, err := Unlock(ctx, c, testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := s.UserKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(k.EncKey, userKey.EncKey) || !bytes.Equal(k.MacKey, userKey.MacKey) {
+		t.Error("Unexpected user key")
+	}
+	pk, err := s.PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rsaKey.Equal(pk) {
+		t.Error("Unexpected private key")
+	}
+
+	keys, err := s.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orgId := "org"
+	ok, err := keys.KeyFor(&orgId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ok.EncKey, make([]byte, 32)) {
+		t.Error("Unexpected organization key")
+	}
+	orgId = "broken"
+	if _, err := keys.KeyFor(&orgId); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Expected error for the broken organization key, got %v", err)
+	}
+
+	// The broken folder doesn't hide the other one.
+	folders, err := s.Folders(ctx)
+	var fe *FolderError
+	if !errors.As(err, &fe) || fe.Id != "2" {
+		t.Errorf("Expected error for folder 2 got %v", err)
+	}
+	if len(folders) != 1 || folders[0].Name != "test" {
+		t.Errorf("Unexpected folders %+v", folders)
+	}
+
+	// The cipher of an unknown organization fails, the one with a broken
+	// note is returned without it.
+	ciphers, err := s.Ciphers(ctx)
+	var ce *CipherError
+	if !errors.As(err, &ce) || ce.Id != "c2" || !strings.Contains(err.Error(), "cipher c3: Notes") {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if len(ciphers) != 2 || ciphers[0].Id != "c1" || ciphers[1].Id != "c3" || *ciphers[1].SecureNote.Name != "test" || ciphers[1].SecureNote.Notes != nil {
+		t.Errorf("Unexpected ciphers %+v", ciphers)
+	}
+
+	// Login derives the master key only once for logging in and unlocking.
+	atomic.StoreInt32(prelogins, 0)
+	s2, err := Login(ctx, testEmail, testPassword, WithServerURL(ts.URL), WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k2, err := s2.UserKey(); err != nil || !bytes.Equal(k2.EncKey, userKey.EncKey) {
+		t.Errorf("Unexpected user key after login: %v", err)
+	}
+	if n := atomic.LoadInt32(prelogins); n != 1 {
+		t.Errorf("Expected 1 prelogin got %d", n)
+	}
+	s2.Lock()
+
+	s.Lock()
+	if !s.Locked() {
+		t.Error("Expected session to be locked")
+	}
+	if !bytes.Equal(k.EncKey, make([]byte, len(k.EncKey))) || !bytes.Equal(k.MacKey, make([]byte, len(k.MacKey))) {
+		t.Error("Expected user key to be wiped")
+	}
+	if pk.D.Sign() != 0 {
+		t.Error("Expected private key to be wiped")
+	}
+	if _, err := s.Folders(ctx); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked got %v", err)
+	}
+	if _, err := s.UserKey(); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked got %v", err)
+	}
+}
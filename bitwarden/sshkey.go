@@ -0,0 +1,66 @@
+package bitwarden
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const minSshRSABits = 2048
+
+// GenerateSshKeyEd25519 generates a new Ed25519 key pair for an SSH key
+// cipher.
+func GenerateSshKeyEd25519() (*SshKeyData, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return newSshKeyData(priv)
+}
+
+// GenerateSshKeyRSA generates a new RSA key pair of at least 2048 bits for
+// an SSH key cipher.
+func GenerateSshKeyRSA(bits int) (*SshKeyData, error) {
+	if bits < minSshRSABits {
+		return nil, fmt.Errorf("bitwarden: RSA key size %d is too small", bits)
+	}
+	priv, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, err
+	}
+	return newSshKeyData(priv)
+}
+
+// ParseSshKey returns the SSH key data of an unencrypted private key in
+// OpenSSH or PEM format. The public key and fingerprint are derived from it.
+func ParseSshKey(privateKey string) (*SshKeyData, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+	if err != nil {
+		return nil, err
+	}
+	return sshKeyData(privateKey, signer.PublicKey()), nil
+}
+
+func newSshKeyData(priv crypto.Signer) (*SshKeyData, error) {
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		return nil, err
+	}
+	pub, err := ssh.NewPublicKey(priv.Public())
+	if err != nil {
+		return nil, err
+	}
+	return sshKeyData(string(pem.EncodeToMemory(block)), pub), nil
+}
+
+func sshKeyData(privateKey string, pub ssh.PublicKey) *SshKeyData {
+	publicKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+	fingerprint := ssh.FingerprintSHA256(pub)
+	return &SshKeyData{PrivateKey: &privateKey, PublicKey: &publicKey, KeyFingerprint: &fingerprint}
+}
@@ -1,5 +1,7 @@
 package bitwarden
 
+import "context"
+
 const (
 	PATH_SYNC = "sync"
 )
@@ -8,10 +10,13 @@ type SyncService struct {
 	client *Client
 }
 
-func (c *SyncService) GetSync() (SyncData, error) {
-	req, err := c.client.newRequest("GET", PATH_SYNC, nil)
-
+func (c *SyncService) GetSync(ctx context.Context) (SyncData, error) {
 	var syncData SyncData
+	req, err := c.client.newRequest(ctx, "GET", PATH_SYNC, nil)
+	if err != nil {
+		return syncData, err
+	}
+
 	_, err = c.client.do(req, &syncData)
 
 	return syncData, err
@@ -3,7 +3,8 @@ package bitwarden
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -14,32 +15,76 @@ const (
 	CipherType_SecureNote = iota
 	CipherType_Card       = iota
 	CipherType_Identity   = iota
+	CipherType_SshKey     = iota
 )
 
 const (
 	FieldType_Text    = iota
 	FieldType_Hidden  = iota
 	FieldType_Boolean = iota
+	FieldType_Linked  = iota
 )
 
 const (
 	SecureNoteType_Generic = iota
 )
 
+const (
+	CipherRepromptType_None     = 0
+	CipherRepromptType_Password = 1
+)
+
+const (
+	UriMatchType_Domain            = 0
+	UriMatchType_Host              = 1
+	UriMatchType_StartsWith        = 2
+	UriMatchType_Exact             = 3
+	UriMatchType_RegularExpression = 4
+	UriMatchType_Never             = 5
+)
+
+const (
+	SendType_Text = 0
+	SendType_File = 1
+)
+
+const (
+	FileUploadType_Direct = 0
+	FileUploadType_Azure  = 1
+)
+
+const (
+	OrganizationUserType_Owner   = 0
+	OrganizationUserType_Admin   = 1
+	OrganizationUserType_User    = 2
+	OrganizationUserType_Manager = 3
+	OrganizationUserType_Custom  = 4
+)
+
+const (
+	OrganizationUserStatus_Revoked   = -1
+	OrganizationUserStatus_Invited   = 0
+	OrganizationUserStatus_Accepted  = 1
+	OrganizationUserStatus_Confirmed = 2
+)
+
 type Keys struct {
-	EncryptedPrivateKey string `json:"encryptedPrivateKey"`
-	PublicKey           string `json:"publicKey"`
+	EncryptedPrivateKey CipherString `json:"encryptedPrivateKey"`
+	PublicKey           string       `json:"publicKey"`
 }
 
 type Account struct {
-	Id                 string  `json:"id"`
-	Name               string  `json:"name"`
-	Email              string  `json:"email"`
-	MasterPasswordHash string  `json:"masterPasswordHash"`
-	MasterPasswordHint *string `json:"masterPasswordHint,omitempty"`
-	Key                string  `json:"key"`
-	Keys               Keys    `json:"keys"`
-	RefreshToken       string  `json:"-"`
+	Id                 string       `json:"id"`
+	Name               string       `json:"name"`
+	Email              string       `json:"email"`
+	MasterPasswordHash string       `json:"masterPasswordHash"`
+	MasterPasswordHint *string      `json:"masterPasswordHint,omitempty"`
+	Key                CipherString `json:"key"`
+	PrivateKey         CipherString `json:"privateKey"`
+	Keys               Keys         `json:"keys"`
+	RefreshToken       string       `json:"-"`
+
+	Organizations []ProfileOrganizationResponse `json:"organizations"`
 }
 
 type User struct {
@@ -70,21 +115,62 @@ type User struct {
 	RevisionDate Time
 }
 
-// The data we store
-type Cipher struct {
+// EncryptedCipher is a cipher as the server stores it. Decrypt it to a
+// CipherView to read it.
+type EncryptedCipher struct {
 	Type                int
 	FolderId            *string // Must be pointer to output null in json. Android app will crash if not null
 	OrganizationId      *string
 	Favorite            bool
 	Edit                bool
-	Id                  string          `json:"Id,omitempty"`
-	Login               *LoginData      `json:"Login,omitempty"`
-	Card                *CardData       `json:"Card,omitempty"`
-	SecureNote          *SecureNoteData `json:"SecureNote,omitempty"`
-	Identity            *IdentityData   `json:"Identity,omitempty"`
-	Attachments         []string
+	Id                  string                   `json:"Id,omitempty"`
+	Login               *EncryptedLoginData      `json:"Login,omitempty"`
+	Card                *EncryptedCardData       `json:"Card,omitempty"`
+	SecureNote          *EncryptedSecureNoteData `json:"SecureNote,omitempty"`
+	Identity            *EncryptedIdentityData   `json:"Identity,omitempty"`
+	SshKey              *EncryptedSshKeyData     `json:"SshKey,omitempty"`
+	Attachments         []Attachment             `json:"Attachments,omitempty"`
 	OrganizationUseTotp bool
-	RevisionDate        *Time `json:"RevisionDate,omitempty"`
+	ViewPassword        bool
+	Permissions         *CipherPermissions             `json:"Permissions,omitempty"`
+	PasswordHistory     []EncryptedPasswordHistoryData `json:"PasswordHistory,omitempty"`
+	Reprompt            int                            // CipherRepromptType_*
+	Key                 *CipherString                  `json:"Key,omitempty"` // per-item key, encrypted with the user or organization key
+	CreationDate        *Time                          `json:"CreationDate,omitempty"`
+	RevisionDate        *Time                          `json:"RevisionDate,omitempty"`
+	DeletedDate         *Time                          `json:"DeletedDate,omitempty"` // set while in the trash
+	CollectionIds       []string                       `json:"CollectionIds,omitempty"`
+	// RawData is the data of cipher types this package doesn't know.
+	RawData json.RawMessage `json:"RawData,omitempty"`
+}
+
+// CipherView is a decrypted cipher. Encrypt it to an EncryptedCipher to
+// store it.
+type CipherView struct {
+	Type                int
+	FolderId            *string
+	OrganizationId      *string
+	Favorite            bool
+	Edit                bool
+	Id                  string           `json:"Id,omitempty"`
+	Login               *LoginData       `json:"Login,omitempty"`
+	Card                *CardData        `json:"Card,omitempty"`
+	SecureNote          *SecureNoteData  `json:"SecureNote,omitempty"`
+	Identity            *IdentityData    `json:"Identity,omitempty"`
+	SshKey              *SshKeyData      `json:"SshKey,omitempty"`
+	Attachments         []AttachmentView `json:"Attachments,omitempty"`
+	OrganizationUseTotp bool
+	ViewPassword        bool
+	Permissions         *CipherPermissions    `json:"Permissions,omitempty"`
+	PasswordHistory     []PasswordHistoryData `json:"PasswordHistory,omitempty"`
+	Reprompt            int                   // CipherRepromptType_*
+	Key                 *CipherString         `json:"Key,omitempty"` // stays wrapped
+	CreationDate        *Time                 `json:"CreationDate,omitempty"`
+	RevisionDate        *Time                 `json:"RevisionDate,omitempty"`
+	DeletedDate         *Time                 `json:"DeletedDate,omitempty"`
+	CollectionIds       []string              `json:"CollectionIds,omitempty"`
+	// RawData is the still encrypted data of unknown cipher types.
+	RawData json.RawMessage `json:"RawData,omitempty"`
 }
 
 type Profile struct {
@@ -96,16 +182,16 @@ type Profile struct {
 	MasterPasswordHint string
 	Culture            string
 	TwoFactorEnabled   bool
-	Key                string
-	PrivateKey         string
+	Key                CipherString
+	PrivateKey         CipherString
 	SecurityStamp      string
-	Organizations      []string
+	Organizations      []ProfileOrganizationResponse
 	Object             string
 }
 
 type SyncData struct {
 	Profile Profile
-	Folders []Folder
+	Folders []EncryptedFolder
 	Ciphers []CipherDetailsResponse `json:"Ciphers,omitempty"`
 	Domains Domains
 	Object  string
@@ -123,6 +209,24 @@ type GlobalEquivalentDomains struct {
 	Excluded bool
 }
 
+// SecureNoteType is one of SecureNoteType_*. It is an int, but the web vault
+// sends it as a string.
+type SecureNoteType int
+
+func (t *SecureNoteType) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), "\"")
+	if s == "" || s == "null" {
+		*t = SecureNoteType_Generic
+		return nil
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("bitwarden: invalid secure note type %s", b)
+	}
+	*t = SecureNoteType(i)
+	return nil
+}
+
 type Time struct {
 	time.Time
 }
@@ -136,17 +240,170 @@ func (t *Time) UnmarshalJSON(b []byte) error {
 
 func (t Time) MarshalJSON() ([]byte, error) {
 	b, err := t.Time.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
 	s := string(b[:len(b)-2]) + "\""
-	return []byte(s), err
+	return []byte(s), nil
 }
 
-type Folder struct {
+type EncryptedFolder struct {
 	Id           string
-	Name         string
+	Name         CipherString
 	Object       string
 	RevisionDate *Time
 }
 
+type FolderView struct {
+	Id           string
+	Name         string
+	RevisionDate *Time
+}
+
+// Attachment is the metadata of a file attached to a cipher. FileName and
+// Key are encrypted with the cipher's key, the file itself with Key.
+type Attachment struct {
+	Id       string
+	Url      string
+	FileName CipherString
+	Key      CipherString
+	Size     json.Number // in bytes, some servers send it as a string
+	SizeName string
+	Object   string `json:"Object,omitempty"`
+}
+
+// AttachmentView is the decrypted metadata of an attachment. Key stays
+// wrapped with the cipher's key.
+type AttachmentView struct {
+	Id       string
+	Url      string
+	FileName string
+	Key      CipherString
+	Size     json.Number
+	SizeName string
+}
+
+// EncryptedSend is a Bitwarden Send as stored on the server. Name, Notes,
+// Text and File.FileName are encrypted with the send key, which is derived
+// from the key material in Key. Key is encrypted with the user key.
+type EncryptedSend struct {
+	Id             string `json:"Id,omitempty"`
+	AccessId       string `json:"AccessId,omitempty"`
+	Type           int    // SendType_*
+	Name           CipherString
+	Notes          *CipherString
+	Key            CipherString
+	Text           *EncryptedSendText `json:"Text,omitempty"`
+	File           *EncryptedSendFile `json:"File,omitempty"`
+	MaxAccessCount *int
+	AccessCount    int
+	// Password is the hash of the access password. The server returns its
+	// own hash, which is never sent back, see SendView.SetPassword.
+	Password       *string
+	Disabled       bool
+	HideEmail      bool
+	RevisionDate   *Time `json:"RevisionDate,omitempty"`
+	ExpirationDate *Time
+	// DeletionDate is required, the server deletes the Send at that time.
+	DeletionDate *Time
+	Object       string `json:"Object,omitempty"`
+}
+
+type EncryptedSendText struct {
+	Text   *CipherString
+	Hidden bool
+}
+
+type EncryptedSendFile struct {
+	Id       string `json:"Id,omitempty"`
+	FileName CipherString
+	Size     json.Number `json:"Size,omitempty"` // in bytes
+	SizeName string      `json:"SizeName,omitempty"`
+}
+
+// SendView is a decrypted Send. Key stays encrypted.
+type SendView struct {
+	Id             string
+	AccessId       string
+	Type           int // SendType_*
+	Name           string
+	Notes          *string
+	Key            CipherString
+	Text           *SendText
+	File           *SendFile
+	MaxAccessCount *int
+	AccessCount    int
+	// Password is the server's hash of the access password, nil if the Send
+	// isn't password protected.
+	Password       *string
+	Disabled       bool
+	HideEmail      bool
+	RevisionDate   *Time
+	ExpirationDate *Time
+	DeletionDate   *Time
+
+	password *string
+}
+
+type SendText struct {
+	Text   *string
+	Hidden bool
+}
+
+type SendFile struct {
+	Id       string
+	FileName string
+	Size     json.Number // in bytes
+	SizeName string
+}
+
+// SendAccessResponse is an encrypted Send as seen by its recipients.
+type SendAccessResponse struct {
+	Id                string
+	Type              int // SendType_*
+	Name              CipherString
+	Text              *EncryptedSendText
+	File              *EncryptedSendFile
+	ExpirationDate    *Time
+	CreatorIdentifier *string
+	Object            string
+}
+
+// SendAccess is a decrypted Send as seen by its recipients.
+type SendAccess struct {
+	Id                string
+	Type              int // SendType_*
+	Name              string
+	Text              *SendText
+	File              *SendFile
+	ExpirationDate    *Time
+	CreatorIdentifier *string
+
+	key      CryptoKey
+	password *string
+}
+
+// EncryptedCollection is a collection with its name encrypted with the key
+// of the owning organization.
+type EncryptedCollection struct {
+	Id             string
+	OrganizationId string
+	Name           CipherString
+	ExternalId     *string
+	ReadOnly       bool
+	HidePasswords  bool
+	Object         string
+}
+
+type CollectionView struct {
+	Id             string
+	OrganizationId string
+	Name           string
+	ExternalId     *string
+	ReadOnly       bool
+	HidePasswords  bool
+}
+
 type List struct {
 	Object string
 	Data   interface{}
@@ -159,77 +416,207 @@ type CipherRequest struct {
 	OrganizationId *string
 	FolderId       *string
 	Favorite       bool
-	Name           *string
-	Notes          *string
-	Fields         *[]FieldData
-	Attachments    *[]string
+	Name           *CipherString
+	Notes          *CipherString
+	Fields         *[]EncryptedFieldData
+	// Attachments2 maps attachment ids to their encrypted name and key.
+	Attachments2    map[string]CipherAttachmentRequest `json:"Attachments2,omitempty"`
+	PasswordHistory []EncryptedPasswordHistoryData     `json:"PasswordHistory,omitempty"`
+	Reprompt        int
+	Key             *CipherString `json:"Key,omitempty"`
 
-	Login      *LoginData      `json:"Login,omitempty"`
-	Card       *CardData       `json:"Card,omitempty"`
-	SecureNote *SecureNoteData `json:"SecureNote,omitempty"`
-	Identity   *IdentityData   `json:"Identity,omitempty"`
+	Login      *EncryptedLoginData      `json:"Login,omitempty"`
+	Card       *EncryptedCardData       `json:"Card,omitempty"`
+	SecureNote *EncryptedSecureNoteData `json:"SecureNote,omitempty"`
+	Identity   *EncryptedIdentityData   `json:"Identity,omitempty"`
+	SshKey     *EncryptedSshKeyData     `json:"SshKey,omitempty"`
 
 	RevisionDate *Time
+	// LastKnownRevisionDate lets the server reject updates of stale ciphers.
+	LastKnownRevisionDate *Time `json:"LastKnownRevisionDate,omitempty"`
 }
 
-func (cr *CipherRequest) FromCipher(c Cipher) error {
+// FromCipher fills the request from an encrypted cipher. Ciphers of unknown
+// types can't be sent.
+func (cr *CipherRequest) FromCipher(c EncryptedCipher) error {
+	if !knownCipherType(c.Type) {
+		return fmt.Errorf("%w %d", ErrUnknownCipherType, c.Type)
+	}
 	j, err := json.Marshal(c)
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(j, cr)
-	switch c.Type {
-	case CipherType_Login:
-		cr.Name = c.Login.Name
-		cr.Fields = c.Login.Fields
-		cr.Login.Name = nil
-		cr.Login.Fields = nil
-	case CipherType_Card:
-		cr.Name = c.Card.Name
-		cr.Fields = c.Card.Fields
-		cr.Card.Name = nil
-		cr.Card.Fields = nil
-	case CipherType_Identity:
-		cr.Name = c.Identity.Name
-		cr.Fields = c.Identity.Fields
-		cr.Identity.Name = nil
-		cr.Identity.Fields = nil
-	case CipherType_SecureNote:
-		cr.Name = c.SecureNote.Name
-		cr.Fields = c.SecureNote.Fields
-		cr.SecureNote.Name = nil
-		cr.SecureNote.Fields = nil
-	default:
-		log.Fatal("invalid cipher type")
+	if err := json.Unmarshal(j, cr); err != nil {
+		return err
+	}
+	cr.LastKnownRevisionDate = c.RevisionDate
+	if len(c.Attachments) > 0 {
+		cr.Attachments2 = make(map[string]CipherAttachmentRequest, len(c.Attachments))
+		for _, a := range c.Attachments {
+			cr.Attachments2[a.Id] = CipherAttachmentRequest{FileName: a.FileName, Key: a.Key}
+		}
 	}
-	return err
+	d := cr.commonData()
+	cr.Name = d.Name
+	cr.Fields = d.Fields
+	d.Name = nil
+	d.Fields = nil
+	return nil
 }
 
-func (cr *CipherRequest) ToCipher() (Cipher, error) {
-	var c Cipher
+func (cr *CipherRequest) ToCipher() (EncryptedCipher, error) {
+	var c EncryptedCipher
+	if !knownCipherType(cr.Type) {
+		return c, fmt.Errorf("%w %d", ErrUnknownCipherType, cr.Type)
+	}
 	j, err := json.Marshal(cr)
 	if err != nil {
 		return c, err
 	}
-	err = json.Unmarshal(j, &c)
+	if err := json.Unmarshal(j, &c); err != nil {
+		return c, err
+	}
+	for id, a := range cr.Attachments2 {
+		c.Attachments = append(c.Attachments, Attachment{Id: id, FileName: a.FileName, Key: a.Key})
+	}
+	sort.Slice(c.Attachments, func(i, j int) bool { return c.Attachments[i].Id < c.Attachments[j].Id })
+	d := c.commonData()
+	d.Name = cr.Name
+	d.Fields = cr.Fields
+	return c, nil
+}
+
+func knownCipherType(t int) bool {
+	switch t {
+	case CipherType_Login, CipherType_Card, CipherType_Identity, CipherType_SecureNote, CipherType_SshKey:
+		return true
+	}
+	return false
+}
+
+// commonData returns the name, notes and fields of the cipher's data,
+// creating the data if there is none. It returns nil for unknown types.
+func (c *EncryptedCipher) commonData() *EncryptedCipherData {
 	switch c.Type {
 	case CipherType_Login:
-		c.Login.Name = cr.Name
-		c.Login.Fields = cr.Fields
+		if c.Login == nil {
+			c.Login = &EncryptedLoginData{}
+		}
+		return &c.Login.EncryptedCipherData
 	case CipherType_Card:
-		c.Card.Name = cr.Name
-		c.Card.Fields = cr.Fields
+		if c.Card == nil {
+			c.Card = &EncryptedCardData{}
+		}
+		return &c.Card.EncryptedCipherData
 	case CipherType_Identity:
-		c.Identity.Name = cr.Name
-		c.Identity.Fields = cr.Fields
+		if c.Identity == nil {
+			c.Identity = &EncryptedIdentityData{}
+		}
+		return &c.Identity.EncryptedCipherData
 	case CipherType_SecureNote:
-		c.SecureNote.Name = cr.Name
-		c.SecureNote.Fields = cr.Fields
-	default:
-		log.Fatal("invalid cipher type")
+		if c.SecureNote == nil {
+			c.SecureNote = &EncryptedSecureNoteData{}
+		}
+		return &c.SecureNote.EncryptedCipherData
+	case CipherType_SshKey:
+		if c.SshKey == nil {
+			c.SshKey = &EncryptedSshKeyData{}
+		}
+		return &c.SshKey.EncryptedCipherData
 	}
+	return nil
+}
 
-	return c, err
+// commonData is like EncryptedCipher.commonData.
+func (cr *CipherRequest) commonData() *EncryptedCipherData {
+	switch cr.Type {
+	case CipherType_Login:
+		if cr.Login == nil {
+			cr.Login = &EncryptedLoginData{}
+		}
+		return &cr.Login.EncryptedCipherData
+	case CipherType_Card:
+		if cr.Card == nil {
+			cr.Card = &EncryptedCardData{}
+		}
+		return &cr.Card.EncryptedCipherData
+	case CipherType_Identity:
+		if cr.Identity == nil {
+			cr.Identity = &EncryptedIdentityData{}
+		}
+		return &cr.Identity.EncryptedCipherData
+	case CipherType_SecureNote:
+		if cr.SecureNote == nil {
+			cr.SecureNote = &EncryptedSecureNoteData{}
+		}
+		return &cr.SecureNote.EncryptedCipherData
+	case CipherType_SshKey:
+		if cr.SshKey == nil {
+			cr.SshKey = &EncryptedSshKeyData{}
+		}
+		return &cr.SshKey.EncryptedCipherData
+	}
+	return nil
+}
+
+type CipherAttachmentRequest struct {
+	FileName CipherString
+	Key      CipherString
+}
+
+type AttachmentRequest struct {
+	Key          CipherString
+	FileName     CipherString
+	FileSize     int64
+	AdminRequest bool
+}
+
+type SendFileRequest struct {
+	EncryptedSend
+	FileLength int64
+}
+
+type TwoFactorEmailRequest struct {
+	Email              string
+	MasterPasswordHash string
+	DeviceIdentifier   string
+}
+
+type SendAccessRequest struct {
+	Password *string `json:"Password,omitempty"`
+}
+
+type CollectionRequest struct {
+	Name       CipherString
+	ExternalId *string
+}
+
+type CipherCreateRequest struct {
+	Cipher        CipherRequest
+	CollectionIds []string
+}
+
+type CipherBulkRequest struct {
+	Ids []string
+}
+
+type CipherBulkMoveRequest struct {
+	Ids      []string
+	FolderId *string
+}
+
+type CipherWithIdRequest struct {
+	CipherRequest
+	Id string
+}
+
+type CipherBulkShareRequest struct {
+	Ciphers       []CipherWithIdRequest
+	CollectionIds []string
+}
+
+type CipherCollectionsRequest struct {
+	CollectionIds []string
 }
 
 // Response objects
@@ -244,18 +631,54 @@ type ErrorResponse struct {
 	ExceptionMessage    string
 	ExceptionStackTrace string
 	Message             string
-	ValidationErrors    map[string][2]string
+	ValidationErrors    map[string][]string
+
+	// Set by the identity server
+	OAuthError          string `json:"error"`
+	OAuthErrorDesc      string `json:"error_description"`
+	ErrorModel          *ErrorResponse
+	TwoFactorProviders2 map[string]json.RawMessage
+}
+
+type AttachmentUploadDataResponse struct {
+	Response
+
+	AttachmentId       string
+	Url                string
+	FileUploadType     int // FileUploadType_*
+	CipherResponse     *CipherResponse
+	CipherMiniResponse *CipherMiniResponse
+}
+
+type SendFileUploadDataResponse struct {
+	Response
+
+	Url            string
+	FileUploadType int // FileUploadType_*
+	SendResponse   *EncryptedSend
+}
+
+type SendFileDownloadDataResponse struct {
+	Response
+
+	Id  string
+	Url string
 }
 
 type CipherMiniResponse struct {
 	Response
 
-	Id             string
-	OrganizationId *string
-	Type           int
-	Data           interface{}
-	Attachments    []string
-	RevisionDate   *Time
+	Id              string
+	OrganizationId  *string
+	Type            int
+	Data            interface{}
+	Attachments     []Attachment
+	PasswordHistory []EncryptedPasswordHistoryData
+	Reprompt        int
+	Key             *CipherString
+	CreationDate    *Time
+	RevisionDate    *Time
+	DeletedDate     *Time
 }
 
 type CipherResponse struct {
@@ -264,9 +687,16 @@ type CipherResponse struct {
 	FolderId            *string // Must be pointer to output null in json. Android app will crash if not null
 	Favorite            bool
 	Edit                bool
+	ViewPassword        bool
+	Permissions         *CipherPermissions
 	OrganizationUseTotp bool
 }
 
+type CipherPermissions struct {
+	Delete  bool
+	Restore bool
+}
+
 type CipherDetailsResponse struct {
 	CipherResponse
 	CollectionIds []string
@@ -278,9 +708,15 @@ type CipherMiniDetailsResponse struct {
 }
 
 type FieldData struct {
-	Type  int
-	Name  string
-	Value string
+	Type     int // FieldType_*
+	Name     string
+	Value    string
+	LinkedId *int `json:"LinkedId,omitempty"` // for FieldType_Linked
+}
+
+type PasswordHistoryData struct {
+	Password     string
+	LastUsedDate *Time
 }
 
 type CipherData struct {
@@ -291,10 +727,41 @@ type CipherData struct {
 
 type LoginData struct {
 	CipherData
-	URI      *string `json:"Uri"`
-	Username *string `json:"Username"`
-	Password *string `json:"Password"`
-	ToTp     *string `json:"Totp"`
+	// URI is the first of Uris, kept for older servers and clients.
+	URI                  *string                `json:"Uri"`
+	Uris                 *[]LoginUriData        `json:"Uris,omitempty"`
+	Username             *string                `json:"Username"`
+	Password             *string                `json:"Password"`
+	PasswordRevisionDate *Time                  `json:"PasswordRevisionDate,omitempty"`
+	ToTp                 *string                `json:"Totp"`
+	AutofillOnPageLoad   *bool                  `json:"AutofillOnPageLoad,omitempty"`
+	Fido2Credentials     *[]Fido2CredentialData `json:"Fido2Credentials,omitempty"`
+}
+
+// Fido2CredentialData is a passkey. All fields but CreationDate are
+// encrypted, including the counter and discoverable flag.
+type Fido2CredentialData struct {
+	CredentialId    string // a GUID, or "b64." followed by the base64url encoded id
+	KeyType         string // "public-key"
+	KeyAlgorithm    string // "ECDSA"
+	KeyCurve        string // "P-256"
+	KeyValue        string // base64url encoded PKCS #8 private key
+	RpId            string
+	RpName          string
+	UserHandle      string // base64url encoded
+	UserName        string
+	UserDisplayName string
+	Counter         string // decimal
+	Discoverable    string // "true" or "false"
+	CreationDate    Time
+}
+
+type LoginUriData struct {
+	Uri *string
+	// UriChecksum is the base64 encoded SHA-256 of Uri, encrypted. It lets
+	// clients detect URIs that were swapped on the server.
+	UriChecksum *string `json:"UriChecksum,omitempty"`
+	Match       *int    // UriMatchType_*, nil for the user's default
 }
 
 type CardData struct {
@@ -329,12 +796,93 @@ type IdentityData struct {
 	LicenseNumber  *string
 }
 
+type SshKeyData struct {
+	CipherData
+	PrivateKey     *string // OpenSSH format
+	PublicKey      *string // authorized_keys format, without comment
+	KeyFingerprint *string // SHA256:...
+}
+
 type SecureNoteData struct {
 	CipherData
-	Type *string // is int, but sent as string from web
+	Type SecureNoteType
 }
 
 type ProfileOrganizationResponse struct {
+	Response
+
+	Id         string
+	Name       string
+	Identifier *string
+	// Organization key, encrypted with the user's public key. Only set once
+	// the membership is confirmed.
+	Key     CipherString
+	Status  int // OrganizationUserStatus_*
+	Type    int // OrganizationUserType_*
+	Enabled bool
+
+	UsePolicies      bool
+	UseGroups        bool
+	UseDirectory     bool
+	UseEvents        bool
+	UseTotp          bool
+	Use2fa           bool
+	UseApi           bool
+	UseSso           bool
+	UseKeyConnector  bool
+	UseResetPassword bool
+	UsersGetPremium  bool
+	SelfHost         bool
+
+	Seats          *int
+	MaxCollections *int
+	MaxStorageGb   *int
+
+	ResetPasswordEnrolled   bool
+	HasPublicAndPrivateKeys bool
+	KeyConnectorEnabled     bool
+	Permissions             *OrganizationPermissions
+}
+
+// OrganizationPermissions are the permissions of members with the custom
+// role.
+type OrganizationPermissions struct {
+	AccessEventLogs      bool
+	AccessImportExport   bool
+	AccessReports        bool
+	CreateNewCollections bool
+	EditAnyCollection    bool
+	DeleteAnyCollection  bool
+	ManageGroups         bool
+	ManagePolicies       bool
+	ManageSso            bool
+	ManageUsers          bool
+	ManageResetPassword  bool
+	ManageScim           bool
+}
+
+type OrganizationUserResponse struct {
+	Response
+
+	Id                    string
+	UserId                *string
+	Type                  int // OrganizationUserType_*
+	Status                int // OrganizationUserStatus_*
+	ExternalId            *string
+	Name                  *string
+	Email                 string
+	TwoFactorEnabled      bool
+	SsoBound              bool
+	ResetPasswordEnrolled bool
+	UsesKeyConnector      bool
+	Permissions           *OrganizationPermissions
+}
+
+type OrganizationKeysResponse struct {
+	Response
+
+	PublicKey  string
+	PrivateKey CipherString // Encrypted with the organization key
 }
 
 type ProfileResponse struct {
@@ -347,34 +895,29 @@ type ProfileResponse struct {
 	MasterPasswordHint *string
 	Culture            string
 	TwoFactorEnabled   bool
-	Key                string
-	PrivateKey         string
+	Key                CipherString
+	PrivateKey         CipherString
 	SecurityStamp      *string
 
 	Organizations *[]ProfileOrganizationResponse
 }
 
-func (cmr *CipherMiniResponse) ToCipher() Cipher {
-	cipher := Cipher{Id: cmr.Id, Type: cmr.Type, RevisionDate: cmr.RevisionDate, OrganizationId: cmr.OrganizationId, Attachments: cmr.Attachments}
-	v, _ := json.Marshal(cmr.Data)
-	switch cipher.Type {
-	case CipherType_Login:
-		json.Unmarshal(v, &cipher.Login)
-	case CipherType_Card:
-		json.Unmarshal(v, &cipher.Card)
-	case CipherType_Identity:
-		json.Unmarshal(v, &cipher.Identity)
-	case CipherType_SecureNote:
-		json.Unmarshal(v, &cipher.SecureNote)
-	default:
-		log.Fatal("invalid cipher type")
+// ToCipher converts the response to a cipher. The data of unknown cipher
+// types is kept in RawData.
+func (cmr *CipherMiniResponse) ToCipher() (EncryptedCipher, error) {
+	cipher := EncryptedCipher{Id: cmr.Id, Type: cmr.Type, RevisionDate: cmr.RevisionDate, DeletedDate: cmr.DeletedDate, OrganizationId: cmr.OrganizationId, Attachments: cmr.Attachments,
+		PasswordHistory: cmr.PasswordHistory, Reprompt: cmr.Reprompt, Key: cmr.Key, CreationDate: cmr.CreationDate}
+	v, err := json.Marshal(cmr.Data)
+	if err != nil {
+		return cipher, err
 	}
-
-	return cipher
+	err = cipher.UnMarshalData(v)
+	return cipher, err
 }
 
-func NewCipherMiniResponse(cipher Cipher) CipherMiniResponse {
-	cmr := CipherMiniResponse{Id: cipher.Id, Type: cipher.Type, RevisionDate: cipher.RevisionDate, OrganizationId: cipher.OrganizationId, Attachments: cipher.Attachments}
+func NewCipherMiniResponse(cipher EncryptedCipher) CipherMiniResponse {
+	cmr := CipherMiniResponse{Id: cipher.Id, Type: cipher.Type, RevisionDate: cipher.RevisionDate, DeletedDate: cipher.DeletedDate, OrganizationId: cipher.OrganizationId, Attachments: cipher.Attachments,
+		PasswordHistory: cipher.PasswordHistory, Reprompt: cipher.Reprompt, Key: cipher.Key, CreationDate: cipher.CreationDate}
 	switch cipher.Type {
 	case CipherType_Login:
 		cmr.Data = cipher.Login
@@ -384,82 +927,91 @@ func NewCipherMiniResponse(cipher Cipher) CipherMiniResponse {
 		cmr.Data = cipher.Identity
 	case CipherType_SecureNote:
 		cmr.Data = cipher.SecureNote
+	case CipherType_SshKey:
+		cmr.Data = cipher.SshKey
 	default:
-		log.Fatal("invalid cipher type")
+		cmr.Data = cipher.RawData
 	}
 
 	cmr.Object = "cipherMini"
 	return cmr
 }
 
-func (cmr *CipherResponse) ToCipher() Cipher {
-	cipher := cmr.CipherMiniResponse.ToCipher()
+func (cmr *CipherResponse) ToCipher() (EncryptedCipher, error) {
+	cipher, err := cmr.CipherMiniResponse.ToCipher()
 
 	cipher.FolderId = cmr.FolderId
 	cipher.Favorite = cmr.Favorite
 	cipher.Edit = cmr.Edit
+	cipher.ViewPassword = cmr.ViewPassword
+	cipher.Permissions = cmr.Permissions
 	cipher.OrganizationUseTotp = cmr.OrganizationUseTotp
-	return cipher
+	return cipher, err
 }
 
-func NewCipherResponse(cipher Cipher) CipherResponse {
-	cr := CipherResponse{CipherMiniResponse: NewCipherMiniResponse(cipher), FolderId: cipher.FolderId, Favorite: cipher.Favorite, Edit: cipher.Edit, OrganizationUseTotp: cipher.OrganizationUseTotp}
+func NewCipherResponse(cipher EncryptedCipher) CipherResponse {
+	cr := CipherResponse{CipherMiniResponse: NewCipherMiniResponse(cipher), FolderId: cipher.FolderId, Favorite: cipher.Favorite, Edit: cipher.Edit,
+		ViewPassword: cipher.ViewPassword, Permissions: cipher.Permissions, OrganizationUseTotp: cipher.OrganizationUseTotp}
 
 	cr.Object = "cipher"
 	return cr
 }
 
-func (cdr *CipherDetailsResponse) ToCipher() Cipher {
-	cipher := cdr.CipherResponse.ToCipher()
+func (cdr *CipherDetailsResponse) ToCipher() (EncryptedCipher, error) {
+	cipher, err := cdr.CipherResponse.ToCipher()
 
-	//cipher.CollectionIds = cdr.CollectionIds // TODO
-	return cipher
+	cipher.CollectionIds = cdr.CollectionIds
+	return cipher, err
 }
 
-func NewCipherDetailsResponse(cipher Cipher) CipherDetailsResponse {
+func NewCipherDetailsResponse(cipher EncryptedCipher) CipherDetailsResponse {
 	cr := NewCipherResponse(cipher)
 	cdr := CipherDetailsResponse{CipherResponse: cr}
 
-	cdr.CollectionIds = nil // TODO collections
+	cdr.CollectionIds = cipher.CollectionIds
 
 	cdr.Object = "cipherDetails"
 	return cdr
 }
 
-func (cmdr *CipherMiniDetailsResponse) ToCipher() Cipher {
-	cipher := cmdr.CipherMiniResponse.ToCipher()
+func (cmdr *CipherMiniDetailsResponse) ToCipher() (EncryptedCipher, error) {
+	cipher, err := cmdr.CipherMiniResponse.ToCipher()
 
-	//cipher.CollectionIds = cdr.CollectionIds // TODO
-	return cipher
+	cipher.CollectionIds = cmdr.CollectionIds
+	return cipher, err
 }
 
-func NewCipherMiniDetailsResponse(cipher Cipher) CipherMiniDetailsResponse {
+func NewCipherMiniDetailsResponse(cipher EncryptedCipher) CipherMiniDetailsResponse {
 	cmr := NewCipherMiniResponse(cipher)
 	cmdr := CipherMiniDetailsResponse{CipherMiniResponse: cmr}
 
-	cmdr.CollectionIds = nil // TODO collections
+	cmdr.CollectionIds = cipher.CollectionIds
 
 	cmdr.Object = "cipherMiniDetails"
 	return cmdr
 }
 
-func (c *Cipher) UnMarshalData(v []byte) error {
+// UnMarshalData sets the data of the cipher's type, or RawData for unknown
+// types.
+func (c *EncryptedCipher) UnMarshalData(v []byte) error {
 	switch c.Type {
 	case CipherType_Login:
-		json.Unmarshal(v, &c.Login)
+		return json.Unmarshal(v, &c.Login)
 	case CipherType_Card:
-		json.Unmarshal(v, &c.Card)
+		return json.Unmarshal(v, &c.Card)
 	case CipherType_Identity:
-		json.Unmarshal(v, &c.Identity)
+		return json.Unmarshal(v, &c.Identity)
 	case CipherType_SecureNote:
-		json.Unmarshal(v, &c.SecureNote)
+		return json.Unmarshal(v, &c.SecureNote)
+	case CipherType_SshKey:
+		return json.Unmarshal(v, &c.SshKey)
 	default:
-		log.Fatal("invalid cipher type")
+		c.RawData = append(json.RawMessage(nil), v...)
 	}
 	return nil
 }
 
-func (c *Cipher) MarshalData() ([]byte, error) {
+func (c *EncryptedCipher) MarshalData() ([]byte, error) {
 	var v interface{}
 	switch c.Type {
 	case CipherType_Login:
@@ -470,8 +1022,10 @@ func (c *Cipher) MarshalData() ([]byte, error) {
 		v = c.Identity
 	case CipherType_SecureNote:
 		v = c.SecureNote
+	case CipherType_SshKey:
+		v = c.SshKey
 	default:
-		log.Fatal("invalid cipher type")
+		v = c.RawData
 	}
 	return json.Marshal(v)
 }
@@ -2,7 +2,9 @@ package bitwarden
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -56,6 +58,19 @@ func TestDateTimeUnmarshalJSON(t *testing.T) {
 	}
 }
 
+func TestSecureNoteTypeUnmarshalJSON(t *testing.T) {
+	for _, s := range []string{`{"Type":0}`, `{"Type":"0"}`, `{"Type":null}`, `{}`} {
+		var d SecureNoteData
+		if err := json.Unmarshal([]byte(s), &d); err != nil || d.Type != SecureNoteType_Generic {
+			t.Errorf("%s: unexpected type %d: %v", s, d.Type, err)
+		}
+	}
+	var d SecureNoteData
+	if err := json.Unmarshal([]byte(`{"Type":"note"}`), &d); err == nil {
+		t.Error("Expected error for non-numeric type")
+	}
+}
+
 type myTimeStruct struct {
 	Test string
 	Time Time
@@ -78,3 +93,198 @@ func TestDateTimeJSON(t *testing.T) {
 	}
 
 }
+
+const testCipherDetails = `{
+	"id": "c1",
+	"organizationId": null,
+	"folderId": "f1",
+	"type": 1,
+	"data": {
+		"name": "2.bmFtZQ==|bmFtZQ==|bmFtZQ==",
+		"notes": null,
+		"fields": [
+			{"type": 0, "name": "2.Zg==|Zg==|Zg==", "value": "2.dg==|dg==|dg==", "linkedId": null},
+			{"type": 3, "name": "2.Zg==|Zg==|Zg==", "value": null, "linkedId": 101}
+		],
+		"uri": "2.dQ==|dQ==|dQ==",
+		"uris": [
+			{"uri": "2.dQ==|dQ==|dQ==", "uriChecksum": "2.Yw==|Yw==|Yw==", "match": null},
+			{"uri": "2.dTI=|dTI=|dTI=", "uriChecksum": null, "match": 3}
+		],
+		"username": "2.dXNlcg==|dXNlcg==|dXNlcg==",
+		"password": "2.cA==|cA==|cA==",
+		"passwordRevisionDate": "2024-01-02T03:04:05.123Z",
+		"totp": null,
+		"autofillOnPageLoad": true
+	},
+	"favorite": true,
+	"edit": true,
+	"viewPassword": false,
+	"permissions": {"delete": true, "restore": false},
+	"organizationUseTotp": false,
+	"attachments": null,
+	"passwordHistory": [{"password": "2.b2xk|b2xk|b2xk", "lastUsedDate": "2023-12-01T00:00:00Z"}],
+	"reprompt": 1,
+	"key": "2.a2V5|a2V5|a2V5",
+	"creationDate": "2023-11-01T10:00:00Z",
+	"revisionDate": "2024-01-02T03:04:05.123Z",
+	"deletedDate": null,
+	"collectionIds": [],
+	"object": "cipherDetails"
+}`
+
+func TestCipherResponseRoundTrip(t *testing.T) {
+	cdr := CipherDetailsResponse{}
+	if err := json.Unmarshal([]byte(testCipherDetails), &cdr); err != nil {
+		t.Fatal(err)
+	}
+	c, err := cdr.ToCipher()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := c.Login
+	if l == nil || l.Uris == nil || len(*l.Uris) != 2 || l.Fields == nil || len(*l.Fields) != 2 {
+		t.Fatalf("Unexpected login %+v", l)
+	}
+	if u := (*l.Uris)[1]; u.Match == nil || *u.Match != UriMatchType_Exact || u.UriChecksum != nil {
+		t.Errorf("Unexpected uri %+v", u)
+	}
+	if f := (*l.Fields)[1]; f.Type != FieldType_Linked || f.LinkedId == nil || *f.LinkedId != 101 {
+		t.Errorf("Unexpected field %+v", f)
+	}
+	if l.PasswordRevisionDate == nil || l.PasswordRevisionDate.Year() != 2024 || l.AutofillOnPageLoad == nil || !*l.AutofillOnPageLoad {
+		t.Errorf("Unexpected login %+v", l)
+	}
+	if c.Reprompt != CipherRepromptType_Password || c.Key == nil || c.Key.String() != "2.a2V5|a2V5|a2V5" || c.ViewPassword ||
+		c.Permissions == nil || !c.Permissions.Delete || c.CreationDate == nil || c.DeletedDate != nil {
+		t.Errorf("Unexpected cipher %+v", c)
+	}
+	if len(c.PasswordHistory) != 1 || c.PasswordHistory[0].Password.String() != "2.b2xk|b2xk|b2xk" || c.PasswordHistory[0].LastUsedDate == nil {
+		t.Errorf("Unexpected password history %+v", c.PasswordHistory)
+	}
+
+	b, err := json.Marshal(NewCipherDetailsResponse(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cdr = CipherDetailsResponse{}
+	if err := json.Unmarshal(b, &cdr); err != nil {
+		t.Fatal(err)
+	}
+	if rc, err := cdr.ToCipher(); err != nil || !reflect.DeepEqual(rc, c) {
+		t.Errorf("Round trip changed cipher:\n%+v\n%+v", rc, c)
+	}
+}
+
+func TestCipherRequestRoundTrip(t *testing.T) {
+	cdr := CipherDetailsResponse{}
+	if err := json.Unmarshal([]byte(testCipherDetails), &cdr); err != nil {
+		t.Fatal(err)
+	}
+	c, err := cdr.ToCipher()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cr := CipherRequest{}
+	if err := cr.FromCipher(c); err != nil {
+		t.Fatal(err)
+	}
+	if cr.LastKnownRevisionDate != c.RevisionDate || cr.Reprompt != c.Reprompt || cr.Key.String() != c.Key.String() || len(cr.PasswordHistory) != 1 {
+		t.Errorf("Unexpected request %+v", cr)
+	}
+	if cr.Login.Name != nil || cr.Login.Fields != nil || cr.Name == nil || cr.Fields == nil {
+		t.Errorf("Name and fields not moved to the request %+v", cr)
+	}
+
+	rc, err := cr.ToCipher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rc.Login, c.Login) || !reflect.DeepEqual(rc.PasswordHistory, c.PasswordHistory) ||
+		rc.Reprompt != c.Reprompt || rc.Key.String() != c.Key.String() || *rc.FolderId != *c.FolderId {
+		t.Errorf("Round trip changed cipher:\n%+v\n%+v", rc, c)
+	}
+}
+
+const testUnknownCipher = `{"id":"c9","type":42,"data":{"name":"2.a2V5|a2V5|a2V5","x":[1,2]},"revisionDate":"2024-01-02T03:04:05.123Z","object":"cipherDetails"}`
+
+func TestUnknownCipherType(t *testing.T) {
+	cdr := CipherDetailsResponse{}
+	if err := json.Unmarshal([]byte(testUnknownCipher), &cdr); err != nil {
+		t.Fatal(err)
+	}
+	c, err := cdr.ToCipher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(c.RawData) != `{"name":"2.a2V5|a2V5|a2V5","x":[1,2]}` || c.Login != nil {
+		t.Errorf("Unexpected cipher %+v", c)
+	}
+
+	b, err := json.Marshal(NewCipherDetailsResponse(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cdr = CipherDetailsResponse{}
+	if err := json.Unmarshal(b, &cdr); err != nil {
+		t.Fatal(err)
+	}
+	if rc, err := cdr.ToCipher(); err != nil || !reflect.DeepEqual(rc, c) {
+		t.Errorf("Round trip changed cipher: %v\n%+v\n%+v", err, rc, c)
+	}
+
+	cr := CipherRequest{}
+	if err := cr.FromCipher(c); !errors.Is(err, ErrUnknownCipherType) {
+		t.Errorf("Expected ErrUnknownCipherType, got %v", err)
+	}
+}
+
+func TestCipherEncryptRoundTrip(t *testing.T) {
+	_, userKey := testKeys(t)
+	newCipher := func() CipherView {
+		name, user, uri, uri0, totp := "Example", "alice", "https://example.com/login", "https://example.com/login", ""
+		match := UriMatchType_Host
+		autofill := true
+		now := Time{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+		return CipherView{
+			Type: CipherType_Login,
+			Login: &LoginData{
+				CipherData:           CipherData{Name: &name, Fields: &[]FieldData{{Type: FieldType_Text, Name: "pin", Value: "1234"}, {Type: FieldType_Hidden, Name: "empty"}}},
+				URI:                  &uri0,
+				Uris:                 &[]LoginUriData{{Uri: &uri, Match: &match}},
+				Username:             &user,
+				ToTp:                 &totp,
+				PasswordRevisionDate: &now,
+				AutofillOnPageLoad:   &autofill,
+			},
+			PasswordHistory: []PasswordHistoryData{{Password: "hunter2", LastUsedDate: &now}},
+			Reprompt:        CipherRepromptType_Password,
+		}
+	}
+
+	v := newCipher()
+	c, err := v.Encrypt(userKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := (*c.Login.Uris)[0]
+	if u.Uri == nil || u.Uri.IsZero() || u.UriChecksum == nil || c.PasswordHistory[0].Password.IsZero() {
+		t.Fatalf("Expected encrypted cipher %+v", c)
+	}
+	if c.Login.ToTp != nil {
+		t.Errorf("Expected empty TOTP to be cleared")
+	}
+	dv, err := c.Decrypt(userKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := newCipher()
+	expected.Login.ToTp = nil
+	(*expected.Login.Uris)[0].UriChecksum = uriChecksum("https://example.com/login")
+	if !reflect.DeepEqual(*dv, expected) {
+		t.Errorf("Round trip changed cipher:\n%+v\n%+v", *dv, expected)
+	}
+}
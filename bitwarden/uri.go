@@ -0,0 +1,121 @@
+package bitwarden
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Matches reports whether any of the decrypted URIs of a login matches uri.
+func (l *LoginData) Matches(uri string) bool {
+	if l.Uris == nil {
+		return l.URI != nil && (LoginUriData{Uri: l.URI}).Matches(uri)
+	}
+	for _, u := range *l.Uris {
+		if u.Matches(uri) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether the decrypted URI matches uri according to its
+// match type, as the official clients do for autofill. A nil Match is
+// treated as UriMatchType_Domain, the default of the official clients.
+func (u LoginUriData) Matches(uri string) bool {
+	if u.Uri == nil || *u.Uri == "" {
+		return false
+	}
+	match := UriMatchType_Domain
+	if u.Match != nil {
+		match = *u.Match
+	}
+
+	switch match {
+	case UriMatchType_Domain:
+		d := uriDomain(*u.Uri)
+		return d != "" && d == uriDomain(uri)
+	case UriMatchType_Host:
+		h := uriHost(*u.Uri)
+		return h != "" && h == uriHost(uri)
+	case UriMatchType_StartsWith:
+		return strings.HasPrefix(uri, *u.Uri)
+	case UriMatchType_Exact:
+		return uri == *u.Uri
+	case UriMatchType_RegularExpression:
+		re, err := regexp.Compile("(?i)" + *u.Uri)
+		return err == nil && re.MatchString(uri)
+	}
+	return false
+}
+
+// parseUri parses uri, which is often stored without a scheme.
+func parseUri(uri string) *url.URL {
+	uri = strings.TrimSpace(uri)
+	if !strings.Contains(uri, "://") {
+		uri = "http://" + uri
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil
+	}
+	return u
+}
+
+// uriHost returns the host of uri, including the port.
+func uriHost(uri string) string {
+	u := parseUri(uri)
+	if u == nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// uriDomain returns the registrable domain of uri, like example.co.uk for
+// https://www.example.co.uk/login. IP addresses and single label hosts, like
+// localhost, are returned as is.
+func uriDomain(uri string) string {
+	u := parseUri(uri)
+	if u == nil {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" || net.ParseIP(host) != nil || !strings.Contains(host, ".") {
+		return host
+	}
+	d, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return d
+}
+
+// uriChecksum returns the base64 encoded SHA-256 of uri.
+func uriChecksum(uri string) *string {
+	sum := sha256.Sum256([]byte(uri))
+	cs := base64.StdEncoding.EncodeToString(sum[:])
+	return &cs
+}
+
+// SetUris replaces the URIs of a login, using the default match type.
+// Empty URIs are skipped.
+func (l *LoginData) SetUris(uris []string) {
+	lu := make([]LoginUriData, 0, len(uris))
+	for _, uri := range uris {
+		u := strings.TrimSpace(uri)
+		if u != "" {
+			lu = append(lu, LoginUriData{Uri: &u})
+		}
+	}
+	l.Uris = &lu
+	l.URI = nil
+	if len(lu) > 0 {
+		first := *lu[0].Uri
+		l.URI = &first
+	}
+}
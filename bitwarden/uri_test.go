@@ -0,0 +1,46 @@
+package bitwarden
+
+import "testing"
+
+func TestUriMatches(t *testing.T) {
+	match := func(m int) *int { return &m }
+	tests := []struct {
+		uri   string
+		match *int
+		url   string
+		want  bool
+	}{
+		{"https://www.example.co.uk/login", nil, "https://accounts.example.co.uk/", true},
+		{"example.com", nil, "https://login.example.com/", true},
+		{"https://example.co.uk", nil, "https://other.co.uk", false},
+		{"http://192.168.1.1:8080", nil, "https://192.168.1.1/", true},
+		{"http://localhost:3000", nil, "http://localhost:4000", true},
+		{"https://example.com:8443", match(UriMatchType_Host), "https://example.com:8443/a", true},
+		{"https://example.com:8443", match(UriMatchType_Host), "https://example.com/a", false},
+		{"https://www.example.com", match(UriMatchType_Host), "https://example.com", false},
+		{"https://example.com/app", match(UriMatchType_StartsWith), "https://example.com/app/login", true},
+		{"https://example.com/app", match(UriMatchType_StartsWith), "https://example.com/", false},
+		{"https://example.com/", match(UriMatchType_Exact), "https://example.com/", true},
+		{"https://example.com/", match(UriMatchType_Exact), "https://example.com/?a", false},
+		{`^https://[a-z]+\.EXAMPLE\.com/`, match(UriMatchType_RegularExpression), "https://intranet.example.com/", true},
+		{`[`, match(UriMatchType_RegularExpression), "[", false},
+		{"https://example.com", match(UriMatchType_Never), "https://example.com", false},
+		{"", nil, "", false},
+	}
+	for _, tt := range tests {
+		uri := tt.uri
+		u := LoginUriData{Uri: &uri, Match: tt.match}
+		if got := u.Matches(tt.url); got != tt.want {
+			t.Errorf("%q (match %v) matches %q: got %v want %v", tt.uri, tt.match, tt.url, got, tt.want)
+		}
+	}
+
+	l := LoginData{}
+	l.SetUris([]string{"https://a.example.org", " ", "https://b.example.net"})
+	if len(*l.Uris) != 2 || *l.URI != "https://a.example.org" {
+		t.Errorf("Unexpected uris %+v", *l.Uris)
+	}
+	if !l.Matches("https://www.example.net") || l.Matches("https://example.com") {
+		t.Error("Unexpected login match")
+	}
+}
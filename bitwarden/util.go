@@ -1,178 +1,407 @@
 package bitwarden
 
 import (
+	"crypto/rand"
+	"errors"
 	"fmt"
-	"log"
-	"reflect"
 )
 
-func internalDecrypt(v reflect.Value, mk CryptoKey) error {
+// itemKey returns the key a cipher of organizationId is encrypted with: its
+// own item key if it has one, the user or organization key otherwise.
+func itemKey(keys KeyProvider, organizationId *string, key *CipherString) (CryptoKey, error) {
+	mk, err := keys.KeyFor(organizationId)
+	if err != nil || key == nil || key.IsZero() {
+		return mk, err
+	}
+	return key.DecryptKey(mk, AesCbc256_HmacSha256_B64)
+}
 
-	switch v.Kind() {
-	case reflect.Ptr:
-		nv := v.Elem()
+func (c *EncryptedCipher) key(keys KeyProvider) (CryptoKey, error) {
+	return itemKey(keys, c.OrganizationId, c.Key)
+}
 
-		// Check if the pointer is nil
-		if !nv.IsValid() {
-			return nil
-		}
-		err := internalDecrypt(nv, mk)
-		if err != nil {
-			log.Print(err)
-			invalid := (*string)(nil)
-			t := reflect.TypeOf(invalid)
-			v.Set(reflect.Indirect(reflect.New(t)))
-			err = nil
-		}
-		return err
+func (c *CipherView) key(keys KeyProvider) (CryptoKey, error) {
+	return itemKey(keys, c.OrganizationId, c.Key)
+}
 
-	case reflect.Struct:
-		for i := 0; i < v.NumField(); i++ {
-			err := internalDecrypt(v.Field(i), mk)
-			if err != nil {
-				return err
-			}
-		}
-	case reflect.Int:
-		return nil
-	case reflect.String:
-		s, err := DecryptString(v.String(), mk)
-		if err != nil {
-			return err
-		}
-		v.SetString(s)
-	case reflect.Slice:
-		for i := 0; i < v.Len(); i += 1 {
-			err := internalDecrypt(v.Index(i), mk)
-			if err != nil {
-				return err
-			}
-		}
-	default:
-		log.Fatalf("Error, unknown type: %d", v.Kind())
+// NewKey creates a new item key for a cipher, wrapped with the user or
+// organization key. The next Encrypt uses it. Keys of attachments are
+// rewrapped, but attachments created without a key can't be moved to an
+// item key. The cipher is left unchanged on error.
+func (c *CipherView) NewKey(keys KeyProvider) error {
+	old, err := c.key(keys)
+	if err != nil {
+		return err
+	}
+	k, attachments, err := newItemKey(keys, c.OrganizationId, old, c.Attachments)
+	if err != nil {
+		return err
 	}
+	c.Key, c.Attachments = &k, attachments
 	return nil
 }
 
-func internalEncrypt(v reflect.Value, mk CryptoKey) error {
+// newItemKey creates an item key for a cipher of organizationId, wrapped with
+// the user or organization key, and returns it with a copy of attachments
+// whose keys are rewrapped from old to the item key.
+func newItemKey(keys KeyProvider, organizationId *string, old CryptoKey, attachments []AttachmentView) (CipherString, []AttachmentView, error) {
+	mk, err := keys.KeyFor(organizationId)
+	if err != nil {
+		return CipherString{}, nil, err
+	}
+	kb := make([]byte, 64)
+	if _, err := rand.Read(kb); err != nil {
+		return CipherString{}, nil, err
+	}
+	defer zero(kb)
+	k, err := Encrypt(kb, mk)
+	if err != nil {
+		return CipherString{}, nil, err
+	}
+	nk, err := NewCryptoKey(kb, AesCbc256_HmacSha256_B64)
+	if err != nil {
+		return CipherString{}, nil, err
+	}
+	attachments, err = rewrapAttachmentKeys(attachments, old, nk)
+	if err != nil {
+		return CipherString{}, nil, err
+	}
+	return k, attachments, nil
+}
 
-	switch v.Kind() {
-	case reflect.Ptr:
-		nv := v.Elem()
+// MoveToOrganization prepares a cipher for sharing it with organizationId:
+// the item key, if any, is replaced by a new one wrapped with the
+// organization key, and the keys of attachments are rewrapped. Encrypt the
+// cipher afterwards and pass it to CipherService.ShareCiphers. Use
+// Session.MoveToOrganization to give ciphers without an item key one. The
+// cipher is left unchanged on error.
+func (c *CipherView) MoveToOrganization(keys KeyProvider, organizationId string) error {
+	return c.moveToOrganization(keys, organizationId, false)
+}
 
-		// Check if the pointer is nil
-		if !nv.IsValid() {
-			return nil
-		}
-		err := internalEncrypt(nv, mk)
+// moveToOrganization creates an item key for ciphers without one if newKey
+// is set.
+func (c *CipherView) moveToOrganization(keys KeyProvider, organizationId string, newKey bool) error {
+	old, err := c.key(keys)
+	if err != nil {
+		return err
+	}
+	key := c.Key
+	var attachments []AttachmentView
+	if c.Key != nil || newKey {
+		k, a, err := newItemKey(keys, &organizationId, old, c.Attachments)
 		if err != nil {
-			invalid := (*string)(nil)
-			t := reflect.TypeOf(invalid)
-
-			v.Set(reflect.Indirect(reflect.New(t)))
-		}
-		return nil
-	case reflect.Struct:
-		for i := 0; i < v.NumField(); i++ {
-			err := internalEncrypt(v.Field(i), mk)
-			if err != nil {
-				return err
-			}
+			return err
 		}
-	case reflect.Int:
-		return nil
-	case reflect.String:
-		s, err := EncryptString(v.String(), mk)
+		key, attachments = &k, a
+	} else {
+		nk, err := keys.KeyFor(&organizationId)
 		if err != nil {
 			return err
 		}
-		v.SetString(s)
-	case reflect.Slice:
-		for i := 0; i < v.Len(); i += 1 {
-			err := internalEncrypt(v.Index(i), mk)
-			if err != nil {
-				return err
-			}
+		if attachments, err = rewrapAttachmentKeys(c.Attachments, old, nk); err != nil {
+			return err
 		}
-	default:
-		log.Fatalf("Error, unknown type: %d", v.Kind())
 	}
+	c.OrganizationId, c.Key, c.Attachments = &organizationId, key, attachments
 	return nil
 }
 
-func decrypt(data interface{}, mk CryptoKey) error {
-	return internalDecrypt(reflect.ValueOf(data), mk)
-}
-
-func encrypt(data interface{}, mk CryptoKey) error {
-	return internalEncrypt(reflect.ValueOf(data), mk)
+// rewrapAttachmentKeys returns a copy of attachments with their keys
+// rewrapped from old to nk.
+func rewrapAttachmentKeys(attachments []AttachmentView, old, nk CryptoKey) ([]AttachmentView, error) {
+	if attachments == nil {
+		return nil, nil
+	}
+	rewrapped := make([]AttachmentView, len(attachments))
+	for i, a := range attachments {
+		if a.Key.IsZero() {
+			return nil, fmt.Errorf("bitwarden: attachment %s has no key", a.Id)
+		}
+		kb, err := a.Key.Decrypt(old)
+		if err != nil {
+			return nil, err
+		}
+		k, err := Encrypt(kb, nk)
+		zero(kb)
+		if err != nil {
+			return nil, err
+		}
+		a.Key = k
+		rewrapped[i] = a
+	}
+	return rewrapped, nil
 }
 
-func (c *Cipher) Decrypt(mk CryptoKey) error {
-	var err error
+// Decrypt decrypts a cipher with its item key, if it has one, or with the
+// user or organization key. The item key stays wrapped. Fields that fail to
+// decrypt are left empty and reported in the returned error along with the
+// cipher, see FieldError. The RawData of unknown types is kept as is.
+func (c *EncryptedCipher) Decrypt(keys KeyProvider) (*CipherView, error) {
+	mk, err := c.key(keys)
+	if err != nil {
+		return nil, err
+	}
 
+	cr := cryptor{key: mk}
+	v := &CipherView{
+		Type:                c.Type,
+		FolderId:            c.FolderId,
+		OrganizationId:      c.OrganizationId,
+		Favorite:            c.Favorite,
+		Edit:                c.Edit,
+		Id:                  c.Id,
+		OrganizationUseTotp: c.OrganizationUseTotp,
+		ViewPassword:        c.ViewPassword,
+		Permissions:         c.Permissions,
+		PasswordHistory:     decryptPasswordHistory(&cr, c.PasswordHistory),
+		Reprompt:            c.Reprompt,
+		Key:                 c.Key,
+		CreationDate:        c.CreationDate,
+		RevisionDate:        c.RevisionDate,
+		DeletedDate:         c.DeletedDate,
+		CollectionIds:       c.CollectionIds,
+	}
 	switch c.Type {
 	case CipherType_Login:
-		err = decrypt(&c.Login, mk)
+		v.Login = c.Login.decrypt(&cr)
 	case CipherType_Card:
-		err = decrypt(&c.Card, mk)
+		v.Card = c.Card.decrypt(&cr)
 	case CipherType_Identity:
-		err = decrypt(&c.Identity, mk)
+		v.Identity = c.Identity.decrypt(&cr)
 	case CipherType_SecureNote:
-		err = decrypt(&c.SecureNote, mk)
+		v.SecureNote = c.SecureNote.decrypt(&cr)
+	case CipherType_SshKey:
+		v.SshKey = c.SshKey.decrypt(&cr)
 	default:
-		log.Fatal("invalid cipher type")
+		v.RawData = c.RawData
 	}
-	return err
+	if c.Attachments != nil {
+		v.Attachments = make([]AttachmentView, len(c.Attachments))
+		for i, a := range c.Attachments {
+			v.Attachments[i] = AttachmentView{
+				Id:       a.Id,
+				Url:      a.Url,
+				FileName: cr.decrypt(fmt.Sprintf("Attachments[%d].FileName", i), a.FileName),
+				Key:      a.Key,
+				Size:     a.Size,
+				SizeName: a.SizeName,
+			}
+		}
+	}
+	return v, cr.err()
 }
 
-func (c *Cipher) Encrypt(mk CryptoKey) error {
-	var err error
+// Encrypt encrypts a cipher like Decrypt decrypts it. See NewKey to create
+// an item key for new ciphers.
+func (c *CipherView) Encrypt(keys KeyProvider) (*EncryptedCipher, error) {
+	mk, err := c.key(keys)
+	if err != nil {
+		return nil, err
+	}
 
+	cr := cryptor{key: mk}
+	e := &EncryptedCipher{
+		Type:                c.Type,
+		FolderId:            c.FolderId,
+		OrganizationId:      c.OrganizationId,
+		Favorite:            c.Favorite,
+		Edit:                c.Edit,
+		Id:                  c.Id,
+		OrganizationUseTotp: c.OrganizationUseTotp,
+		ViewPassword:        c.ViewPassword,
+		Permissions:         c.Permissions,
+		PasswordHistory:     encryptPasswordHistory(&cr, c.PasswordHistory),
+		Reprompt:            c.Reprompt,
+		Key:                 c.Key,
+		CreationDate:        c.CreationDate,
+		RevisionDate:        c.RevisionDate,
+		DeletedDate:         c.DeletedDate,
+		CollectionIds:       c.CollectionIds,
+	}
 	switch c.Type {
 	case CipherType_Login:
-		err = encrypt(&c.Login, mk)
+		e.Login = c.Login.encrypt(&cr)
 	case CipherType_Card:
-		err = encrypt(&c.Card, mk)
+		e.Card = c.Card.encrypt(&cr)
 	case CipherType_Identity:
-		err = encrypt(&c.Identity, mk)
+		e.Identity = c.Identity.encrypt(&cr)
 	case CipherType_SecureNote:
-		err = encrypt(&c.SecureNote, mk)
+		e.SecureNote = c.SecureNote.encrypt(&cr)
+	case CipherType_SshKey:
+		e.SshKey = c.SshKey.encrypt(&cr)
 	default:
-		log.Fatal("invalid cipher type")
+		e.RawData = c.RawData
+	}
+	if c.Attachments != nil {
+		e.Attachments = make([]Attachment, len(c.Attachments))
+		for i, a := range c.Attachments {
+			e.Attachments[i] = Attachment{
+				Id:       a.Id,
+				Url:      a.Url,
+				FileName: cr.encrypt(fmt.Sprintf("Attachments[%d].FileName", i), a.FileName),
+				Key:      a.Key,
+				Size:     a.Size,
+				SizeName: a.SizeName,
+			}
+		}
+	}
+	if err := cr.err(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (f *EncryptedFolder) Decrypt(mk CryptoKey) (*FolderView, error) {
+	name, err := f.Name.Decrypt(mk)
+	if err != nil {
+		return nil, err
 	}
-	return err
+	return &FolderView{Id: f.Id, Name: string(name), RevisionDate: f.RevisionDate}, nil
 }
 
-func (f *Folder) Decrypt(mk CryptoKey) error {
-	var err error
-	f.Name, err = DecryptString(f.Name, mk)
-	return err
+func (f *FolderView) Encrypt(mk CryptoKey) (*EncryptedFolder, error) {
+	if f.Name == "" {
+		return nil, errors.New("bitwarden: folder name is empty")
+	}
+	name, err := Encrypt([]byte(f.Name), mk)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptedFolder{Id: f.Id, Name: name, RevisionDate: f.RevisionDate}, nil
 }
 
-func (f *Folder) Encrypt(mk CryptoKey) error {
-	var err error
-	f.Name, err = EncryptString(f.Name, mk)
-	return err
+// Collection names are encrypted with the key of the owning organization.
+func (c *EncryptedCollection) Decrypt(keys KeyProvider) (*CollectionView, error) {
+	mk, err := keys.KeyFor(&c.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	name, err := c.Name.Decrypt(mk)
+	if err != nil {
+		return nil, err
+	}
+	return &CollectionView{Id: c.Id, OrganizationId: c.OrganizationId, Name: string(name), ExternalId: c.ExternalId,
+		ReadOnly: c.ReadOnly, HidePasswords: c.HidePasswords}, nil
 }
 
-func (l List) Decrypt(mk CryptoKey) error {
-	x, ok := (l.Data).([]Decryptable)
-	if !ok {
-		log.Fatal("object doesn't implement Decryptable")
+func (c *CollectionView) Encrypt(keys KeyProvider) (*EncryptedCollection, error) {
+	if c.Name == "" {
+		return nil, errors.New("bitwarden: collection name is empty")
 	}
-	for i, d := range x {
-		err := d.Decrypt(mk)
-		x[i] = d
-		if err != nil {
-			log.Fatal(err)
+	mk, err := keys.KeyFor(&c.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	name, err := Encrypt([]byte(c.Name), mk)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptedCollection{Id: c.Id, OrganizationId: c.OrganizationId, Name: name, ExternalId: c.ExternalId,
+		ReadOnly: c.ReadOnly, HidePasswords: c.HidePasswords}, nil
+}
+
+// SetPassword sets a new access password, which Encrypt hashes with the key
+// material of the Send.
+func (s *SendView) SetPassword(password string) {
+	s.password = &password
+}
+
+// Encrypt encrypts a Send with its send key. New key material is created
+// and kept in Key if Key is empty. The encrypted Send only carries a
+// password hash if a new password was set with SetPassword, so that the
+// server keeps the current password otherwise.
+func (s *SendView) Encrypt(keys KeyProvider) (*EncryptedSend, error) {
+	if s.Name == "" {
+		return nil, errors.New("bitwarden: Send name is empty")
+	}
+	uk, err := keys.KeyFor(nil)
+	if err != nil {
+		return nil, err
+	}
+	var material []byte
+	if s.Key.IsZero() {
+		material = make([]byte, 16)
+		if _, err := rand.Read(material); err != nil {
+			return nil, err
 		}
+		s.Key, err = Encrypt(material, uk)
+	} else {
+		material, err = s.Key.Decrypt(uk)
 	}
-	return nil
+	if err != nil {
+		return nil, err
+	}
+	defer zero(material)
+	key, err := MakeSendKey(material)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cryptor{key: key}
+	e := &EncryptedSend{
+		Id:             s.Id,
+		AccessId:       s.AccessId,
+		Type:           s.Type,
+		Name:           c.encrypt("Name", s.Name),
+		Notes:          c.encryptPtr("Notes", s.Notes),
+		Key:            s.Key,
+		MaxAccessCount: s.MaxAccessCount,
+		AccessCount:    s.AccessCount,
+		Disabled:       s.Disabled,
+		HideEmail:      s.HideEmail,
+		RevisionDate:   s.RevisionDate,
+		ExpirationDate: s.ExpirationDate,
+		DeletionDate:   s.DeletionDate,
+	}
+	if s.Text != nil {
+		e.Text = &EncryptedSendText{Text: c.encryptPtr("Text.Text", s.Text.Text), Hidden: s.Text.Hidden}
+	}
+	if s.File != nil {
+		e.File = &EncryptedSendFile{Id: s.File.Id, FileName: c.encrypt("File.FileName", s.File.FileName), Size: s.File.Size, SizeName: s.File.SizeName}
+	}
+	if err := c.err(); err != nil {
+		return nil, err
+	}
+	if s.password != nil {
+		h := HashSendPassword(*s.password, material)
+		e.Password = &h
+	}
+	return e, nil
 }
 
-type Decryptable interface {
-	Decrypt(mk CryptoKey) error
+// Decrypt decrypts a Send. Fields that fail to decrypt are left empty and
+// reported in the returned error along with the Send, see FieldError.
+func (s *EncryptedSend) Decrypt(keys KeyProvider) (*SendView, error) {
+	key, err := s.key(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cryptor{key: key}
+	v := &SendView{
+		Id:             s.Id,
+		AccessId:       s.AccessId,
+		Type:           s.Type,
+		Name:           c.decrypt("Name", s.Name),
+		Notes:          c.decryptPtr("Notes", s.Notes),
+		Key:            s.Key,
+		MaxAccessCount: s.MaxAccessCount,
+		AccessCount:    s.AccessCount,
+		Password:       s.Password,
+		Disabled:       s.Disabled,
+		HideEmail:      s.HideEmail,
+		RevisionDate:   s.RevisionDate,
+		ExpirationDate: s.ExpirationDate,
+		DeletionDate:   s.DeletionDate,
+	}
+	if s.Text != nil {
+		v.Text = &SendText{Text: c.decryptPtr("Text.Text", s.Text.Text), Hidden: s.Text.Hidden}
+	}
+	if s.File != nil {
+		v.File = &SendFile{Id: s.File.Id, FileName: c.decrypt("File.FileName", s.File.FileName), Size: s.File.Size, SizeName: s.File.SizeName}
+	}
+	return v, c.err()
 }
 
 func DecryptString(s string, mk CryptoKey) (string, error) {
@@ -209,6 +438,5 @@ func EncryptValue(v []byte, mk CryptoKey) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	r := cs.ToString()
-	return r, err
+	return cs.String(), nil
 }
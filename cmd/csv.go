@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"log"
+	"strings"
 
 	"fmt"
 	"github.com/philhug/bitwarden-client-go/bitwarden"
@@ -13,12 +14,12 @@ const (
 )
 
 type CsvRecord struct {
-	bitwarden.Cipher
+	bitwarden.CipherView
 	Folder string
 }
 
 func (csv *CsvRecord) ToCsv(header string) ([]string, error) {
-	ciph := csv.Cipher
+	ciph := csv.CipherView
 	switch ciph.Type {
 	case bitwarden.CipherType_Login:
 		var name string
@@ -32,7 +33,15 @@ func (csv *CsvRecord) ToCsv(header string) ([]string, error) {
 		if ciph.Login.Notes != nil {
 			notes = *ciph.Login.Notes
 		}
-		if ciph.Login.URI != nil {
+		if ciph.Login.Uris != nil {
+			uris := make([]string, 0, len(*ciph.Login.Uris))
+			for _, u := range *ciph.Login.Uris {
+				if u.Uri != nil {
+					uris = append(uris, *u.Uri)
+				}
+			}
+			uri = strings.Join(uris, ",")
+		} else if ciph.Login.URI != nil {
 			uri = *ciph.Login.URI
 		}
 		if ciph.Login.Username != nil {
@@ -52,7 +61,10 @@ func (csv *CsvRecord) ToCsv(header string) ([]string, error) {
 			notes = *ciph.SecureNote.Notes
 		}
 		return []string{csv.Folder, "", "note", name, notes, "", "", "", "", ""}, nil
-
+	case bitwarden.CipherType_SshKey:
+		// Like the official clients, only logins and notes go into CSV
+		// exports, SSH keys need a JSON export.
+		log.Println("ssh key can't be exported to csv, skipping... ", ciph.Id)
 	default:
 		log.Println("unknown cipher type, skipping... ", ciph.Type)
 	}
@@ -60,7 +72,7 @@ func (csv *CsvRecord) ToCsv(header string) ([]string, error) {
 }
 
 func (csv *CsvRecord) FromCsv(header string, record []string) error {
-	ciph := &csv.Cipher
+	ciph := &csv.CipherView
 
 	switch header {
 	case BITWARDEN_HEADER:
@@ -76,11 +88,11 @@ func (csv *CsvRecord) FromCsv(header string, record []string) error {
 					Notes: &record[4],
 				},
 				// Fields = record[5],
-				URI:      &record[6],
 				Username: &record[7],
 				Password: &record[8],
 				ToTp:     &record[9],
 			}
+			ld.SetUris(strings.Split(record[6], ","))
 			ciph.Login = &ld
 		case "note":
 			ciph.Type = bitwarden.CipherType_SecureNote
@@ -92,7 +104,7 @@ func (csv *CsvRecord) FromCsv(header string, record []string) error {
 			}
 			ciph.SecureNote = &snd
 		default:
-			return fmt.Errorf("unknown cipher type ", record[2])
+			return fmt.Errorf("unknown cipher type %s", record[2])
 		}
 
 	case LASTPASS_HEADER:
@@ -117,10 +129,10 @@ func (csv *CsvRecord) FromCsv(header string, record []string) error {
 					Name:  &record[4],
 				},
 				// Fields = record[5],
-				URI:      &record[0],
 				Username: &record[1],
 				Password: &record[2],
 			}
+			ld.SetUris([]string{record[0]})
 			ciph.Login = &ld
 		}
 
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
@@ -41,60 +42,37 @@ command.`,
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 
+		ctx := context.Background()
+
 		log.Println("Export called for: " + userName)
 		log.Println("Format: " + format)
 		log.Println("Output file: " + filename)
 
-		client, err := bitwarden.NewUserPasswordAuthClient(userName, password)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		var profile bitwarden.Account
-		profile, err = client.Account.GetProfile()
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		dk := bitwarden.MakeKey(password, userName)
-
-		cs, err := bitwarden.NewCipherString(profile.Key)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		mk, err := cs.DecryptKey(dk, bitwarden.AesCbc256_HmacSha256_B64)
+		session, err := login(ctx, clientOptions()...)
 		if err != nil {
 			log.Fatal(err)
 		}
+		defer session.Lock()
+		client := session.Client
 
 		var j []byte
 
 		switch format {
 		case "ciphers":
-			ciphers, err := client.Cipher.ListCiphers()
-			if err != nil {
+			ciphers, err := session.Ciphers(ctx)
+			if err != nil && ciphers == nil {
 				log.Fatal(err)
-			}
-			for _, ciph := range ciphers {
-				err := ciph.Decrypt(mk)
-				if err != nil {
-					log.Fatal(err)
-				}
+			} else if err != nil {
+				log.Println(err)
 			}
 			j, _ = json.MarshalIndent(ciphers, "", "  ")
 
 		case "folders":
-			folders, err := client.Folder.ListFolders()
-			if err != nil {
+			folders, err := session.Folders(ctx)
+			if err != nil && folders == nil {
 				log.Fatal(err)
-			}
-			for i, f := range folders {
-				err := f.Decrypt(mk)
-				folders[i] = f
-				if err != nil {
-					log.Fatal(err)
-				}
+			} else if err != nil {
+				log.Println(err)
 			}
 
 			j, _ = json.MarshalIndent(folders, "", "  ")
@@ -113,28 +91,23 @@ command.`,
 
 			w := csv.NewWriter(wo)
 			w.Write(strings.Split(BITWARDEN_HEADER, ","))
-			folders, err := client.Folder.ListFolders()
-			if err != nil {
+			folders, err := session.Folders(ctx)
+			if err != nil && folders == nil {
 				log.Fatal(err)
+			} else if err != nil {
+				log.Println(err)
 			}
-			for i, f := range folders {
-				err := f.Decrypt(mk)
-				folders[i] = f
+			for _, f := range folders {
 				fldr[f.Id] = f.Name
-				if err != nil {
-					log.Fatal(err)
-				}
 			}
 
-			ciphers, err := client.Cipher.ListCiphers()
-			if err != nil {
+			ciphers, err := session.Ciphers(ctx)
+			if err != nil && ciphers == nil {
 				log.Fatal(err)
+			} else if err != nil {
+				log.Println(err)
 			}
 			for _, ciph := range ciphers {
-				err := ciph.Decrypt(mk)
-				if err != nil {
-					log.Fatal(err)
-				}
 				var folder = ""
 				if ciph.FolderId != nil {
 					folder = fldr[*ciph.FolderId]
@@ -151,46 +124,25 @@ command.`,
 			w.Flush()
 
 		case "sync-raw":
-			sync, err := client.Sync.GetSync()
+			sync, err := client.Sync.GetSync(ctx)
 			if err != nil {
 				log.Fatal(err)
 			}
-
-			ciphs := make([]bitwarden.Cipher, len(sync.Ciphers))
-
-			for i, ciph := range sync.Ciphers {
-				c := ciph.ToCipher()
-				err := c.Decrypt(mk)
-				if err != nil {
-					log.Fatal(err)
-				}
-				ciphs[i] = c
-			}
 			j, _ = json.MarshalIndent(sync, "", "  ")
 
 		case "sync-decrypted":
-			sync, err := client.Sync.GetSync()
+			sync, err := client.Sync.GetSync(ctx)
 			if err != nil {
 				log.Fatal(err)
 			}
-
-			ciphs := make([]bitwarden.Cipher, len(sync.Ciphers))
-
-			for i, ciph := range sync.Ciphers {
-				c := ciph.ToCipher()
-				err := c.Decrypt(mk)
-				if err != nil {
-					log.Fatal(err)
-				}
-				ciphs[i] = c
+			keys, err := session.Keys()
+			if err != nil {
+				log.Fatal(err)
 			}
 
-			for i, f := range sync.Folders {
-				err := f.Decrypt(mk)
-				sync.Folders[i] = f
-				if err != nil {
-					log.Fatal(err)
-				}
+			ciphs, _, err := decryptSync(sync, keys)
+			if err != nil {
+				log.Println(err)
 			}
 			j, _ = json.MarshalIndent(ciphs, "", "  ")
 		}
@@ -204,6 +156,43 @@ command.`,
 	},
 }
 
+// decryptSync decrypts the ciphers and folders of a sync. As with
+// Session.Ciphers, items that fail to decrypt are left out and their errors
+// joined.
+func decryptSync(sync bitwarden.SyncData, keys bitwarden.KeyProvider) ([]bitwarden.CipherView, []bitwarden.FolderView, error) {
+	var errs []error
+	ciphs := make([]bitwarden.CipherView, 0, len(sync.Ciphers))
+	for i := range sync.Ciphers {
+		c, err := sync.Ciphers[i].ToCipher()
+		if err != nil {
+			errs = append(errs, &bitwarden.CipherError{Id: sync.Ciphers[i].Id, Err: err})
+			continue
+		}
+		v, err := c.Decrypt(keys)
+		if err != nil {
+			errs = append(errs, &bitwarden.CipherError{Id: c.Id, Err: err})
+		}
+		if v != nil {
+			ciphs = append(ciphs, *v)
+		}
+	}
+
+	uk, err := keys.KeyFor(nil)
+	if err != nil {
+		return ciphs, nil, errors.Join(append(errs, err)...)
+	}
+	folders := make([]bitwarden.FolderView, 0, len(sync.Folders))
+	for i := range sync.Folders {
+		v, err := sync.Folders[i].Decrypt(uk)
+		if err != nil {
+			errs = append(errs, &bitwarden.FolderError{Id: sync.Folders[i].Id, Err: err})
+			continue
+		}
+		folders = append(folders, *v)
+	}
+	return ciphs, folders, errors.Join(errs...)
+}
+
 func IsValidExportFormat(format string) bool {
 	switch format {
 	case
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"encoding/csv"
 	"errors"
 	"fmt"
@@ -36,55 +37,29 @@ var importCmd = &cobra.Command{
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 
+		ctx := context.Background()
+
 		log.Println("Import called for: " + userName)
 		log.Println("Format: " + format)
 		log.Println("Input file: " + filename)
 
-		client, err := bitwarden.NewUserPasswordAuthClient(userName, password)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		var profile bitwarden.Account
-		profile, err = client.Account.GetProfile()
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		dk := bitwarden.MakeKey(password, userName)
-
-		cs, err := bitwarden.NewCipherString(profile.Key)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		mk, err := cs.DecryptKey(dk, bitwarden.AesCbc256_HmacSha256_B64)
-		if err != nil {
-			log.Fatal(err)
-		}
+		// Creating ciphers isn't idempotent, but aborting a large import
+		// half-way on a transient error is worse than a rare duplicate.
+		retryPolicy := bitwarden.DefaultRetryPolicy
+		retryPolicy.RetryNonIdempotent = true
+		opts := append(clientOptions(), bitwarden.WithRetryPolicy(retryPolicy))
 
-		ciphers, err := client.Cipher.ListCiphers()
+		session, err := login(ctx, opts...)
 		if err != nil {
 			log.Fatal(err)
 		}
-		for _, ciph := range ciphers {
-			err := ciph.Decrypt(mk)
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
+		defer session.Lock()
+		client := session.Client
 
-		folders, err := client.Folder.ListFolders()
+		mk, err := session.UserKey()
 		if err != nil {
 			log.Fatal(err)
 		}
-		for i, f := range folders {
-			err := f.Decrypt(mk)
-			folders[i] = f
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
 
 		switch format {
 		case "bitwarden-csv",
@@ -103,13 +78,15 @@ var importCmd = &cobra.Command{
 
 			cr := csv.NewReader(r)
 
-			folder := bitwarden.Folder{}
+			folder := bitwarden.FolderView{}
 			folder.Name = fmt.Sprintf("Import from %s", time.Now().Format(time.RFC822))
 			log.Printf("Importing into new folder \"%s\"", folder.Name)
 
-			folder.Encrypt(mk)
-
-			fldr, err := client.Folder.AddFolder(&folder)
+			encFolder, err := folder.Encrypt(mk)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fldr, err := client.Folder.AddFolder(ctx, encFolder)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -127,16 +104,18 @@ var importCmd = &cobra.Command{
 				if err != nil {
 					log.Fatal(err)
 				}
-				csr.Cipher.FolderId = &fldr.Id
+				csr.CipherView.FolderId = &fldr.Id
 
-				j, _ := json.MarshalIndent(csr.Cipher, "", "  ")
-				log.Println(string(j))
-				break
-				err = csr.Cipher.Encrypt(mk)
+				if dryRun {
+					j, _ := json.MarshalIndent(csr.CipherView, "", "  ")
+					log.Println(string(j))
+					break
+				}
+				c, err := session.EncryptCipher(&csr.CipherView)
 				if err != nil {
 					log.Fatal(err)
 				}
-				_, err = client.Cipher.AddCipher(&csr.Cipher)
+				_, err = client.Cipher.AddCipher(ctx, c)
 				if err != nil {
 					log.Fatal(err)
 				}
@@ -147,45 +126,19 @@ var importCmd = &cobra.Command{
 		case "folders":
 
 		case "sync-raw":
-			sync, err := client.Sync.GetSync()
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			ciphs := make([]bitwarden.Cipher, len(sync.Ciphers))
-
-			for i, ciph := range sync.Ciphers {
-				c := ciph.ToCipher()
-				err := c.Decrypt(mk)
-				if err != nil {
-					log.Fatal(err)
-				}
-				ciphs[i] = c
-			}
 
 		case "sync-decrypted":
-			sync, err := client.Sync.GetSync()
+			sync, err := client.Sync.GetSync(ctx)
 			if err != nil {
 				log.Fatal(err)
 			}
-
-			ciphs := make([]bitwarden.Cipher, len(sync.Ciphers))
-
-			for i, ciph := range sync.Ciphers {
-				c := ciph.ToCipher()
-				err := c.Decrypt(mk)
-				if err != nil {
-					log.Fatal(err)
-				}
-				ciphs[i] = c
+			keys, err := session.Keys()
+			if err != nil {
+				log.Fatal(err)
 			}
 
-			for i, f := range sync.Folders {
-				err := f.Decrypt(mk)
-				sync.Folders[i] = f
-				if err != nil {
-					log.Fatal(err)
-				}
+			if _, _, err := decryptSync(sync, keys); err != nil {
+				log.Println(err)
 			}
 		}
 	},
@@ -203,6 +156,10 @@ func IsValidImportFormat(format string) bool {
 	return false
 }
 
+var dryRun bool
+
 func init() {
 	RootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().BoolVar(&dryRun, "dry-run", true, "only print the first record instead of importing")
 }
@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 
 	homedir "github.com/mitchellh/go-homedir"
+	"github.com/philhug/bitwarden-client-go/bitwarden"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -12,6 +16,12 @@ import (
 var cfgFile string
 var userName string
 var password string
+var serverURL string
+var debug bool
+var twoFactorCode string
+var twoFactorMethod int
+var clientId string
+var clientSecret string
 
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
@@ -47,6 +57,12 @@ func init() {
 
 	RootCmd.PersistentFlags().StringVarP(&userName, "username", "u", "", "username/email")
 	RootCmd.PersistentFlags().StringVarP(&password, "password", "p", "", "password")
+	RootCmd.PersistentFlags().StringVar(&serverURL, "server", "", "Bitwarden server URL (default is bitwarden.com)")
+	RootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "log API calls to stderr")
+	RootCmd.PersistentFlags().StringVar(&twoFactorCode, "2fa-code", "", "two-factor authentication code")
+	RootCmd.PersistentFlags().IntVar(&twoFactorMethod, "2fa-method", 0, "two-factor provider: 0 authenticator, 1 email, 3 yubikey")
+	RootCmd.PersistentFlags().StringVar(&clientId, "client-id", "", "API key client_id, logs in with the API key instead of the password (default is $BW_CLIENTID)")
+	RootCmd.PersistentFlags().StringVar(&clientSecret, "client-secret", "", "API key client_secret (default is $BW_CLIENTSECRET)")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -74,3 +90,46 @@ func initConfig() {
 		fmt.Println("Using config file:", viper.ConfigFileUsed())
 	}
 }
+
+// clientOptions returns the bitwarden.Client options selected by the flags.
+func clientOptions() []bitwarden.Option {
+	var opts []bitwarden.Option
+	if serverURL != "" {
+		opts = append(opts, bitwarden.WithServerURL(serverURL))
+	}
+	if debug {
+		opts = append(opts, bitwarden.WithDebugLogger(log.New(os.Stderr, "", log.LstdFlags)))
+	}
+	if twoFactorCode != "" {
+		opts = append(opts, bitwarden.WithTwoFactor(bitwarden.TwoFactorProvider(twoFactorMethod), twoFactorCode, false))
+	}
+	return opts
+}
+
+// login authenticates with the API key if one was given and with username and
+// password otherwise, and unlocks the vault.
+func login(ctx context.Context, opts ...bitwarden.Option) (*bitwarden.Session, error) {
+	if clientId == "" {
+		clientId = os.Getenv("BW_CLIENTID")
+	}
+	if clientSecret == "" {
+		clientSecret = os.Getenv("BW_CLIENTSECRET")
+	}
+	if clientId == "" {
+		s, err := bitwarden.Login(ctx, userName, password, opts...)
+		// Email codes are only sent on request.
+		if errors.Is(err, bitwarden.ErrTwoFactorRequired) && twoFactorCode == "" && bitwarden.TwoFactorProvider(twoFactorMethod) == bitwarden.TwoFactorProvider_Email {
+			if err := bitwarden.SendEmailLoginCode(ctx, userName, password, opts...); err != nil {
+				return nil, err
+			}
+			return nil, errors.New("two-factor code sent by email, repeat with --2fa-code")
+		}
+		return s, err
+	}
+
+	client, err := bitwarden.NewAPIKeyAuthClient(ctx, clientId, clientSecret, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return bitwarden.Unlock(ctx, client, password)
+}
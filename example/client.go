@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/philhug/bitwarden-client-go/bitwarden"
@@ -9,62 +10,58 @@ import (
 
 func main() {
 
+	ctx := context.Background()
 	var username = "user@example.com"
 	var password = "password"
 
-	client, err := bitwarden.NewUserPasswordAuthClient(username, password)
+	session, err := bitwarden.Login(ctx, username, password, bitwarden.WithServerURL("http://localhost:8080/"))
 	if err != nil {
 		log.Fatal(err)
 	}
-	var profile bitwarden.Account
-	profile, err = client.Account.GetProfile()
+	defer session.Lock()
+	client := session.Client
+
+	mk, err := session.UserKey()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	dk := bitwarden.MakeKey(password, username)
-
-	cs, err := bitwarden.NewCipherString(profile.Key)
+	s := "Test"
+	v := bitwarden.CipherView{Type: bitwarden.CipherType_Login, Login: &bitwarden.LoginData{CipherData: bitwarden.CipherData{Name: &s}}}
+	c, err := v.Encrypt(mk)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	mk, err := cs.DecryptKey(dk, bitwarden.AesCbc256_HmacSha256_B64)
+	cipher, err := client.Cipher.AddCipher(ctx, c)
 	if err != nil {
 		log.Fatal(err)
 	}
+	fmt.Println(cipher)
 
-	s := "Test"
-	c := bitwarden.Cipher{Type: 1, Login: &bitwarden.LoginData{CipherData: bitwarden.CipherData{Name: &s}}}
-	err = c.Encrypt(mk)
+	ciphers, err := client.Cipher.ListCiphers(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
+	fmt.Println(ciphers)
 
-	cipher, err := client.Cipher.AddCipher(&c)
+	view, err := cipher.Decrypt(mk)
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Println(cipher)
-
-	ciphers, err := client.Cipher.ListCiphers()
+	fmt.Println(view.Login)
+	pass := "bla"
+	view.Login.Password = &pass
+	c, err = view.Encrypt(mk)
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Println(ciphers)
-	fmt.Println(cipher)
-	fmt.Println(cipher.Login)
-
-	cipher.Decrypt(mk)
-	pass := "bla"
-	cipher.Login.Password = &pass
-	cipher.Encrypt(mk)
-	cipher, err = client.Cipher.UpdateCipher(cipher)
+	cipher, err = client.Cipher.UpdateCipher(ctx, c)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	ciphers, err = client.Cipher.ListCiphers()
+	ciphers, err = client.Cipher.ListCiphers(ctx)
 	if err != nil {
 		fmt.Println(err)
 		return